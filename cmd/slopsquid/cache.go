@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/QRY91/slopsquid/internal/cache"
+	"github.com/QRY91/slopsquid/internal/detector"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Inspect or clear the scan result cache",
+	Long: `scan, score and report keep each file's result in a cache keyed on the file's
+content, the active rule set and the slopsquid version. Unchanged files are
+not rescanned; editing a file, changing presets or upgrading misses the cache.
+
+Pass --no-cache to any command to bypass it.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCacheInfo()
+	},
+}
+
+var cacheCleanCmd = &cobra.Command{
+	Use:   "clean",
+	Short: "Remove every cached scan result",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir, err := resolveCacheDir()
+		if err != nil {
+			return err
+		}
+		n, err := cache.Clean(dir)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("removed %d cached results from %s\n", n, dir)
+		return nil
+	},
+}
+
+func init() {
+	cacheCmd.AddCommand(cacheCleanCmd)
+}
+
+func resolveCacheDir() (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	return cache.DefaultDir()
+}
+
+func runCacheInfo() error {
+	dir, err := resolveCacheDir()
+	if err != nil {
+		return err
+	}
+	entries, size, err := cache.Stats(dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("cache: %s\n", dir)
+	fmt.Printf("  %d results, %.1f KB\n", entries, float64(size)/1024)
+	return nil
+}
+
+// openScanCache returns the result cache for d, or nil when --no-cache is set
+// or the cache can't be opened. A nil cache scans everything.
+func openScanCache(d *detector.Detector) *cache.Cache {
+	if noCache {
+		return nil
+	}
+	dir, err := resolveCacheDir()
+	if err == nil {
+		var c *cache.Cache
+		if c, err = cache.Open(dir, d.RuleSetHash(), version); err == nil {
+			return c
+		}
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "cache disabled: %v\n", err)
+	}
+	return nil
+}
+
+// scanCached returns the cached result for text, scanning and storing it on
+// a miss
+func scanCached(d *detector.Detector, c *cache.Cache, text string) *detector.ScanResult {
+	if result, ok := c.Get(text); ok {
+		return result
+	}
+	result := d.Scan(text)
+	if err := c.Put(text, result); err != nil && verbose {
+		fmt.Fprintf(os.Stderr, "cache write failed: %v\n", err)
+	}
+	return result
+}
+
+// reportCacheStats prints cache hits and misses in verbose mode
+func reportCacheStats(c *cache.Cache) {
+	if c == nil || !verbose {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "cache: %d reused, %d scanned\n", c.Hits, c.Misses)
+}
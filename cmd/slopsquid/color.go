@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Terminal state, set up once by setupTerminal before any command runs
+var (
+	colorOn    bool
+	termWidth  int // 0 means don't wrap
+	colorCodes = map[string]string{
+		"high":     "1;31",
+		"medium":   "33",
+		"low":      "36",
+		"heavy":    "1;31",
+		"moderate": "33",
+		"clean":    "32",
+		"dim":      "2",
+		"bold":     "1",
+	}
+)
+
+// setupTerminal resolves --color against NO_COLOR and whether stdout is a
+// terminal, and measures the width used for wrapping
+func setupTerminal(mode string) error {
+	tty := isTerminal(os.Stdout)
+
+	switch mode {
+	case "auto":
+		colorOn = autoColor(tty)
+	case "always":
+		colorOn = true
+	case "never":
+		colorOn = false
+	default:
+		return fmt.Errorf("invalid --color %q (want auto, always or never)", mode)
+	}
+
+	termWidth = 0
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		termWidth = cols
+	} else if tty {
+		termWidth = terminalWidth(os.Stdout)
+	}
+	return nil
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// paint wraps s in the ANSI color for a severity, rating or style name
+func paint(style, s string) string {
+	code, ok := colorCodes[style]
+	if !colorOn || !ok || s == "" {
+		return s
+	}
+	return "\x1b[" + code + "m" + s + "\x1b[0m"
+}
+
+// visibleLen counts the runes of s that take up a column, skipping ANSI
+// escape sequences
+func visibleLen(s string) int {
+	n := 0
+	for i := 0; i < len(s); {
+		if s[i] == '\x1b' {
+			if end := strings.IndexByte(s[i:], 'm'); end >= 0 {
+				i += end + 1
+				continue
+			}
+		}
+		_, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		n++
+	}
+	return n
+}
+
+// autoColor is --color=auto: color on a terminal, unless NO_COLOR is set to
+// a non-empty value (see no-color.org) or the terminal is dumb
+func autoColor(tty bool) bool {
+	return tty && os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb"
+}
+
+// wrap breaks s on spaces to fit the terminal, indenting continuation lines.
+// A prefix of spaces counts toward the first line, so pass it in s rather
+// than prepending it. Without a known width the text is returned unchanged.
+func wrap(s string, indent int) string {
+	if termWidth <= indent+20 || visibleLen(s) <= termWidth {
+		return s
+	}
+
+	pad := strings.Repeat(" ", indent)
+	var b strings.Builder
+	lineLen := 0
+	for i, word := range strings.Split(s, " ") {
+		wl := visibleLen(word)
+		if i > 0 {
+			if lineLen+1+wl > termWidth && lineLen > indent {
+				b.WriteString("\n" + pad)
+				lineLen = indent
+			} else {
+				b.WriteByte(' ')
+				lineLen++
+			}
+		}
+		b.WriteString(word)
+		lineLen += wl
+	}
+	return b.String()
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/QRY91/slopsquid/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var compareThreshold float64
+
+var compareCmd = &cobra.Command{
+	Use:   "compare <a> <b>",
+	Short: "Compare two reports, directories or sites page by page",
+	Long: `Compare scores two corpora and shows how b differs from a. Each side may be
+a saved JSON report (slopsquid report --json), a local directory, or a URL.
+
+  slopsquid compare staging.example.com docs.example.com
+  slopsquid compare before.json ./out/
+  slopsquid compare ./batch-v1 ./batch-v2 --format json
+
+Pages are matched by their path relative to the source, so the same page on
+two hosts, or the same file under two directories, lines up.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCompare(args[0], args[1])
+	},
+}
+
+func init() {
+	compareCmd.Flags().Float64Var(&compareThreshold, "threshold", 5, "score change that counts as a regression or improvement")
+	compareCmd.Flags().StringVar(&outputFormat, "format", "text", "output format: text, json, csv, tsv or jsonl (one row per page)")
+	compareCmd.Flags().IntVar(&reportDepth, "depth", 10, "maximum crawl depth (URLs only)")
+	compareCmd.Flags().IntVar(&reportMax, "max-pages", 200, "maximum pages to crawl (URLs only)")
+	compareCmd.Flags().IntVar(&reportDelay, "delay", 200, "delay between requests to a host in ms, across all workers (URLs only)")
+	compareCmd.Flags().IntVar(&reportWorkers, "workers", 3, "concurrent requests (URLs only)")
+	compareCmd.Flags().IntVar(&reportBurst, "burst", 1, "requests a host may receive back to back before --delay applies (URLs only)")
+	addScopeFlags(compareCmd)
+	compareCmd.Flags().IntVar(&reportRetries, "retries", 3, "retries for timeouts, 429 and 5xx responses, with backoff (URLs only)")
+}
+
+// loadReport reads a saved JSON report, or scores a directory, URL or archive
+func loadReport(target string) (*siteReport, error) {
+	if strings.HasSuffix(strings.ToLower(target), ".json") {
+		if info, err := os.Stat(target); err == nil && !info.IsDir() {
+			return readReportFile(target)
+		}
+	}
+	return buildReport(target, nil)
+}
+
+// readReportFile loads a report written by report --json
+func readReportFile(path string) (*siteReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc reportDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: not a JSON report: %w", path, err)
+	}
+	if doc.Schema != "slopsquid.report" {
+		return nil, fmt.Errorf("%s: not a slopsquid report (schema %q)", path, doc.Schema)
+	}
+	if doc.SchemaVersion > reportSchemaVersion {
+		return nil, fmt.Errorf("%s: report schema version %d is newer than this slopsquid supports (%d)",
+			path, doc.SchemaVersion, reportSchemaVersion)
+	}
+
+	rep := &siteReport{
+		Source:       doc.Source,
+		Mode:         doc.Mode,
+		Results:      doc.Pages,
+		TotalPages:   doc.Aggregates.PagesScanned,
+		SkippedPages: doc.Skipped,
+		NotCrawled:   doc.NotCrawled,
+		TotalWords:   doc.Aggregates.TotalWords,
+		TotalHits:    doc.Aggregates.TotalHits,
+		RuleSetHash:  doc.RuleSetHash,
+		StartedAt:    doc.StartedAt,
+		FinishedAt:   doc.FinishedAt,
+		Crawl:        doc.Crawl,
+	}
+	for _, r := range rep.Results {
+		if r.Result == nil {
+			return nil, fmt.Errorf("%s: page %s has no result", path, r.URL)
+		}
+	}
+	return rep, nil
+}
+
+// pageKey is a page's path relative to its report's source, which is what
+// pages are matched on between the two sides
+func pageKey(rep *siteReport, page string) string {
+	if rep.Mode == "crawl" {
+		u, err := url.Parse(page)
+		if err != nil {
+			return page
+		}
+		key := u.EscapedPath()
+		if key == "" {
+			key = "/"
+		}
+		// Pages on other hosts in the crawl's scope keep their host
+		if !sameHost(page, rep.Source) {
+			key = u.Host + key
+		}
+		if u.RawQuery != "" {
+			key += "?" + u.RawQuery
+		}
+		return key
+	}
+
+	// Local reports record the source as an absolute path but their pages as
+	// given on the command line
+	abs, err := filepath.Abs(page)
+	if err != nil {
+		return filepath.ToSlash(page)
+	}
+	rel, err := filepath.Rel(rep.Source, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.ToSlash(page)
+	}
+	if rel == "." {
+		// The source was a single file
+		return filepath.Base(page)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ruleChange is how often one rule fired on each side
+type ruleChange struct {
+	Rule   string `json:"rule"`
+	Type   string `json:"type"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+	Delta  int    `json:"delta"`
+}
+
+// pageDiff is one matched (or unmatched) page
+type pageDiff struct {
+	history.PageChange
+	HitsAdded   int          `json:"hits_added"`
+	HitsRemoved int          `json:"hits_removed"`
+	Rules       []ruleChange `json:"rules"` // only rules whose count changed
+}
+
+// compareSide describes one of the two inputs
+type compareSide struct {
+	Source      string       `json:"source"`
+	Mode        string       `json:"mode"`
+	RuleSetHash string       `json:"rule_set_hash"`
+	Aggregates  reportTotals `json:"aggregates"`
+}
+
+// compareDelta is after minus before for the headline aggregates
+type compareDelta struct {
+	PagesScored  int     `json:"pages_scored"`
+	TotalWords   int     `json:"total_words"`
+	TotalHits    int     `json:"total_hits"`
+	Clean        int     `json:"clean"`
+	Moderate     int     `json:"moderate"`
+	Heavy        int     `json:"heavy"`
+	AverageScore float64 `json:"average_score"`
+}
+
+// compareDocument is the --json form of a comparison
+type compareDocument struct {
+	Schema        string       `json:"schema"`
+	SchemaVersion int          `json:"schema_version"`
+	Tool          reportTool   `json:"tool"`
+	Before        compareSide  `json:"before"`
+	After         compareSide  `json:"after"`
+	RulesChanged  bool         `json:"rules_changed"` // the sides were scored with different rule sets
+	Delta         compareDelta `json:"delta"`
+	Rules         []ruleChange `json:"rules"`
+	Pages         []pageDiff   `json:"pages"`
+}
+
+// ruleKey identifies a rule across both sides
+type ruleKey struct {
+	Type string
+	Rule string
+}
+
+// countRules tallies hits per rule
+func countRules(results []crawlResult) map[ruleKey]int {
+	counts := make(map[ruleKey]int)
+	for _, r := range results {
+		for _, h := range r.Result.Hits {
+			counts[ruleKey{h.Type, h.Rule}]++
+		}
+	}
+	return counts
+}
+
+// diffRules merges two rule tallies, keeping only rules whose count changed,
+// largest change first
+func diffRules(before, after map[ruleKey]int) []ruleChange {
+	merged := make(map[ruleKey]*ruleChange)
+	get := func(k ruleKey) *ruleChange {
+		m, ok := merged[k]
+		if !ok {
+			m = &ruleChange{Rule: k.Rule, Type: k.Type}
+			merged[k] = m
+		}
+		return m
+	}
+	for k, n := range before {
+		get(k).Before = n
+	}
+	for k, n := range after {
+		get(k).After = n
+	}
+
+	changes := []ruleChange{}
+	for _, m := range merged {
+		m.Delta = m.After - m.Before
+		if m.Delta != 0 {
+			changes = append(changes, *m)
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		ai, aj := changes[i].Delta, changes[j].Delta
+		if ai < 0 {
+			ai = -ai
+		}
+		if aj < 0 {
+			aj = -aj
+		}
+		if ai != aj {
+			return ai > aj
+		}
+		if changes[i].Type != changes[j].Type {
+			return changes[i].Type < changes[j].Type
+		}
+		return changes[i].Rule < changes[j].Rule
+	})
+	return changes
+}
+
+func newCompareSide(rep *siteReport) compareSide {
+	return compareSide{
+		Source:      rep.Source,
+		Mode:        rep.Mode,
+		RuleSetHash: rep.RuleSetHash,
+		Aggregates: reportTotals{
+			PagesScanned:  rep.TotalPages,
+			PagesScored:   len(rep.Results),
+			PagesSkipped:  len(rep.SkippedPages),
+			TotalWords:    rep.TotalWords,
+			TotalHits:     rep.TotalHits,
+			reportSummary: summarize(rep.Results),
+		},
+	}
+}
+
+// compareReports matches the pages of a and b by path and diffs their scores
+// and rule counts
+func compareReports(a, b *siteReport, threshold float64) compareDocument {
+	doc := compareDocument{
+		Schema:        "slopsquid.compare",
+		SchemaVersion: 1,
+		Tool:          reportTool{Name: "slopsquid", Version: version},
+		Before:        newCompareSide(a),
+		After:         newCompareSide(b),
+		RulesChanged:  a.RuleSetHash != b.RuleSetHash,
+	}
+	ba, aa := doc.Before.Aggregates, doc.After.Aggregates
+	doc.Delta = compareDelta{
+		PagesScored:  aa.PagesScored - ba.PagesScored,
+		TotalWords:   aa.TotalWords - ba.TotalWords,
+		TotalHits:    aa.TotalHits - ba.TotalHits,
+		Clean:        aa.Clean - ba.Clean,
+		Moderate:     aa.Moderate - ba.Moderate,
+		Heavy:        aa.Heavy - ba.Heavy,
+		AverageScore: aa.AverageScore - ba.AverageScore,
+	}
+	doc.Rules = diffRules(countRules(a.Results), countRules(b.Results))
+
+	pagesA := make(map[string]crawlResult, len(a.Results))
+	pagesB := make(map[string]crawlResult, len(b.Results))
+	var scoresA, scoresB []history.PageScore
+	for _, r := range a.Results {
+		key := pageKey(a, r.URL)
+		pagesA[key] = r
+		scoresA = append(scoresA, history.PageScore{Path: key, Score: r.Result.Score, Rating: r.Result.Rating})
+	}
+	for _, r := range b.Results {
+		key := pageKey(b, r.URL)
+		pagesB[key] = r
+		scoresB = append(scoresB, history.PageScore{Path: key, Score: r.Result.Score, Rating: r.Result.Rating})
+	}
+
+	doc.Pages = []pageDiff{}
+	for _, c := range history.ComparePages(scoresA, scoresB, threshold) {
+		var before, after []crawlResult
+		if r, ok := pagesA[c.Path]; ok {
+			before = []crawlResult{r}
+		}
+		if r, ok := pagesB[c.Path]; ok {
+			after = []crawlResult{r}
+		}
+		pd := pageDiff{PageChange: c, Rules: diffRules(countRules(before), countRules(after))}
+		for _, rc := range pd.Rules {
+			if rc.Delta > 0 {
+				pd.HitsAdded += rc.Delta
+			} else {
+				pd.HitsRemoved -= rc.Delta
+			}
+		}
+		doc.Pages = append(doc.Pages, pd)
+	}
+	return doc
+}
+
+func runCompare(a, b string) error {
+	format, err := resolveFormat("text", "json", "csv", "tsv", "jsonl")
+	if err != nil {
+		return err
+	}
+
+	before, err := loadReport(a)
+	if err != nil {
+		return fmt.Errorf("%s: %w", a, err)
+	}
+	after, err := loadReport(b)
+	if err != nil {
+		return fmt.Errorf("%s: %w", b, err)
+	}
+
+	doc := compareReports(before, after, compareThreshold)
+
+	switch format {
+	case "json":
+		data, _ := json.MarshalIndent(doc, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	case "csv", "tsv", "jsonl":
+		return writeCompareRows(os.Stdout, format, doc)
+	}
+	printCompare(os.Stdout, doc)
+	return nil
+}
+
+var compareColumns = []string{
+	"path", "status", "before", "after", "delta", "before_rating", "after_rating", "hits_added", "hits_removed",
+}
+
+// writeCompareRows writes one compareColumns row per page
+func writeCompareRows(w io.Writer, format string, doc compareDocument) error {
+	rows, err := newRowWriter(w, format, compareColumns)
+	if err != nil {
+		return err
+	}
+	for _, p := range doc.Pages {
+		row := []string{
+			p.Path,
+			p.Status,
+			formatFloat(p.Before),
+			formatFloat(p.After),
+			formatFloat(p.Delta),
+			p.BeforeRating,
+			p.AfterRating,
+			strconv.Itoa(p.HitsAdded),
+			strconv.Itoa(p.HitsRemoved),
+		}
+		if err := rows.write(p, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printCompare(w io.Writer, doc compareDocument) {
+	ba, aa := doc.Before.Aggregates, doc.After.Aggregates
+
+	fmt.Fprintf(w, "\n== SlopSquid Compare ==\n")
+	fmt.Fprintf(w, "   A: %s\n", doc.Before.Source)
+	fmt.Fprintf(w, "   B: %s\n", doc.After.Source)
+	if doc.RulesChanged {
+		fmt.Fprintf(w, "   %s\n", paint("medium", "note: the two sides were scored with different rule sets"))
+	}
+
+	fmt.Fprintf(w, "\n-- Aggregates --\n")
+	fmt.Fprintf(w, "  %-16s %8s %8s  %s\n", "", "A", "B", "change")
+	fmt.Fprintf(w, "  %-16s %8.1f %8.1f  %s\n", "average score", ba.AverageScore, aa.AverageScore, formatDelta(doc.Delta.AverageScore))
+	countRow := func(label string, x, y, d int) {
+		fmt.Fprintf(w, "  %-16s %8d %8d  %+d\n", label, x, y, d)
+	}
+	countRow("pages scored", ba.PagesScored, aa.PagesScored, doc.Delta.PagesScored)
+	countRow("words", ba.TotalWords, aa.TotalWords, doc.Delta.TotalWords)
+	countRow("hits", ba.TotalHits, aa.TotalHits, doc.Delta.TotalHits)
+	countRow("clean", ba.Clean, aa.Clean, doc.Delta.Clean)
+	countRow("moderate", ba.Moderate, aa.Moderate, doc.Delta.Moderate)
+	countRow("heavy", ba.Heavy, aa.Heavy, doc.Delta.Heavy)
+
+	if len(doc.Rules) > 0 {
+		fmt.Fprintf(w, "\n-- Hits by rule --\n")
+		limit := len(doc.Rules)
+		if !verbose && limit > 15 {
+			limit = 15
+		}
+		for _, rc := range doc.Rules[:limit] {
+			fmt.Fprintf(w, "  %s %4d → %-4d [%s] %s\n", formatCount(rc.Delta), rc.Before, rc.After, rc.Type, rc.Rule)
+		}
+		if limit < len(doc.Rules) {
+			fmt.Fprintf(w, "  ... and %d more (use -v to see all)\n", len(doc.Rules)-limit)
+		}
+	}
+
+	sections := []struct {
+		status string
+		title  string
+	}{
+		{history.Regressed, "Worse in B"},
+		{history.Added, "Only in B"},
+		{history.Improved, "Better in B"},
+		{history.Removed, "Only in A"},
+	}
+	for _, sec := range sections {
+		var lines []pageDiff
+		for _, p := range doc.Pages {
+			if p.Status == sec.status {
+				lines = append(lines, p)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n-- %s (%d) --\n", sec.title, len(lines))
+		for _, p := range lines {
+			fmt.Fprintf(w, "  %s\n", formatPageChange(p.PageChange))
+			if verbose {
+				for _, rc := range p.Rules {
+					fmt.Fprintf(w, "      %s [%s] %s\n", formatCount(rc.Delta), rc.Type, rc.Rule)
+				}
+			}
+		}
+	}
+
+	unchanged := 0
+	for _, p := range doc.Pages {
+		if p.Status == history.Unchanged {
+			unchanged++
+		}
+	}
+	fmt.Fprintf(w, "\n  %d pages unchanged (within %.0f points)\n\n", unchanged, compareThreshold)
+}
+
+// formatCount shows a signed hit count change, red for more hits
+func formatCount(d int) string {
+	s := fmt.Sprintf("%+4d", d)
+	if d > 0 {
+		return paint("high", s)
+	}
+	return paint("clean", s)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QRY91/slopsquid/internal/history"
+)
+
+func TestCompareDirectories(t *testing.T) {
+	root := t.TempDir()
+	calm := "The report lists each page with its score and the rules that matched it. "
+	slop := "We delve into a rich tapestry and a testament to the vibrant landscape. "
+	files := map[string]string{
+		"v1/intro.md":       strings.Repeat(calm, 4),
+		"v1/guide/setup.md": strings.Repeat(calm, 4),
+		"v1/old.md":         strings.Repeat(calm, 4),
+		"v2/intro.md":       strings.Repeat(slop, 4),
+		"v2/guide/setup.md": strings.Repeat(calm, 4),
+		"v2/new.md":         strings.Repeat(calm, 4),
+	}
+	for name, text := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Keep scan results out of the user's cache
+	oldCacheDir := cacheDir
+	cacheDir = t.TempDir()
+	t.Cleanup(func() { cacheDir = oldCacheDir })
+
+	// Relative arguments, as typed on the command line
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(root); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	before, err := loadReport("v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after, err := loadReport("./v2/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"intro.md":       history.Regressed,
+		"guide/setup.md": history.Unchanged,
+		"old.md":         history.Removed,
+		"new.md":         history.Added,
+	}
+	doc := compareReports(before, after, 5)
+	if len(doc.Pages) != len(want) {
+		var paths []string
+		for _, p := range doc.Pages {
+			paths = append(paths, p.Path)
+		}
+		t.Fatalf("pages = %q, want %d matched by relative path", paths, len(want))
+	}
+	for _, p := range doc.Pages {
+		if p.Status != want[p.Path] {
+			t.Errorf("%s is %s, want %s", p.Path, p.Status, want[p.Path])
+		}
+	}
+}
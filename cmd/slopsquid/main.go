@@ -1,14 +1,20 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"sort"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/QRY91/slopsquid/internal/archive"
+	"github.com/QRY91/slopsquid/internal/config"
 	"github.com/QRY91/slopsquid/internal/crawler"
 	"github.com/QRY91/slopsquid/internal/detector"
 	"github.com/QRY91/slopsquid/internal/scanner"
@@ -24,12 +30,45 @@ var (
 	jsonOut   bool
 	presets   []string
 	presetDir string
+	colorMode string
+	noCache   bool
+	cacheDir  string
+
+	// File discovery flags, merged with .slopsquid.json
+	configPath   string
+	includeGlobs []string
+	excludeGlobs []string
+	noIgnore     bool
+
+	// Crawl scope flags, merged with .slopsquid.json
+	crawlHosts        []string
+	crawlIncludePaths []string
+	crawlExcludePaths []string
+	crawlIncludeURLs  []string
+	crawlExcludeURLs  []string
+
+	// Output format for scan, score and report
+	outputFormat string
+
+	// Scan flags
+	groupBy string
 
 	// Report flags
 	reportDepth   int
 	reportMax     int
 	reportDelay   int
 	reportWorkers int
+	reportBurst   int
+	reportRetries int
+	reportOutput  string
+	reportSitemap []string
+	reportSince   string
+	reportMirror  string
+
+	// History flags (report, trend)
+	stateDir        string
+	reportNoHistory bool
+	reportFresh     bool
 )
 
 func main() {
@@ -47,6 +86,12 @@ that are statistically overrepresented in LLM output relative to human writing.
 
 Based on frequency-ratio data from the Antislop paper (Paech et al., 2025),
 which analyzed 67 AI models against human writing baselines.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := setupTerminal(colorMode); err != nil {
+			return err
+		}
+		return loadConfig()
+	},
 }
 
 var scanCmd = &cobra.Command{
@@ -54,6 +99,11 @@ var scanCmd = &cobra.Command{
 	Short: "Scan files and report slop hits with scoring",
 	Args:  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		switch groupBy {
+		case "file", "rule", "severity":
+		default:
+			return fmt.Errorf("invalid --group-by %q (want file, rule or severity)", groupBy)
+		}
 		return runScan(args)
 	},
 }
@@ -68,7 +118,7 @@ var scoreCmd = &cobra.Command{
 }
 
 var reportCmd = &cobra.Command{
-	Use:   "report <url|directory>",
+	Use:   "report <url|directory|archive>",
 	Short: "Generate a consolidated slop report for a site or directory",
 	Long: `Report generates a consolidated slop analysis. Accepts either:
 
@@ -78,8 +128,14 @@ var reportCmd = &cobra.Command{
   A directory: slopsquid report ./src/
                Scans local files recursively with HTML text extraction
 
-Both produce the same report format: per-page scores, aggregate stats,
-and the most frequent slop patterns across the entire corpus.`,
+  An archive:  slopsquid report capture.warc.gz
+               Crawls a WARC or HAR file, or a wget mirror with --mirror,
+               offline, reporting pages under their original URLs
+
+All produce the same report format: per-page scores, aggregate stats,
+and the most frequent slop patterns across the entire corpus.
+
+  --format html -o report.html writes a self-contained HTML report.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return runReport(args[0])
@@ -92,16 +148,43 @@ func init() {
 	rootCmd.PersistentFlags().BoolVar(&jsonOut, "json", false, "output as JSON")
 	rootCmd.PersistentFlags().StringSliceVar(&presets, "preset", nil, "load additional detection presets by name, file path, or .json file")
 	rootCmd.PersistentFlags().StringVar(&presetDir, "preset-dir", "", "directory to search for preset .json files by name")
+	rootCmd.PersistentFlags().StringVar(&colorMode, "color", "auto", "colorize output: auto, always or never (honors NO_COLOR)")
+	rootCmd.PersistentFlags().BoolVar(&noCache, "no-cache", false, "rescan every file instead of reusing cached results")
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "project config file (default: nearest .slopsquid.json)")
+	rootCmd.PersistentFlags().StringArrayVar(&includeGlobs, "include", nil, "only scan files matching this glob (repeatable, e.g. 'docs/**/*.{md,txt}')")
+	rootCmd.PersistentFlags().StringArrayVar(&excludeGlobs, "exclude", nil, "skip files and directories matching this glob (repeatable)")
+	rootCmd.PersistentFlags().BoolVar(&noIgnore, "no-ignore", false, "scan files listed in .gitignore and .slopsquidignore too")
+	rootCmd.PersistentFlags().StringVar(&cacheDir, "cache-dir", "", "scan result cache directory (default $XDG_CACHE_HOME/slopsquid)")
+
+	scanCmd.Flags().StringVar(&groupBy, "group-by", "file", "group hits by file, rule or severity")
+	scanCmd.Flags().StringVar(&outputFormat, "format", "text", "output format: text, json, csv, tsv or jsonl (one row per hit)")
+	scoreCmd.Flags().StringVar(&outputFormat, "format", "text", "output format: text, json, csv, tsv or jsonl")
 
 	reportCmd.Flags().IntVar(&reportDepth, "depth", 10, "maximum crawl depth (URLs only)")
 	reportCmd.Flags().IntVar(&reportMax, "max-pages", 200, "maximum pages to crawl (URLs only)")
-	reportCmd.Flags().IntVar(&reportDelay, "delay", 200, "delay between requests in ms (URLs only)")
+	reportCmd.Flags().IntVar(&reportDelay, "delay", 200, "delay between requests to a host in ms, across all workers (URLs only)")
 	reportCmd.Flags().IntVar(&reportWorkers, "workers", 3, "concurrent requests (URLs only)")
+	reportCmd.Flags().IntVar(&reportBurst, "burst", 1, "requests a host may receive back to back before --delay applies (URLs only)")
+	reportCmd.Flags().IntVar(&reportRetries, "retries", 3, "retries for timeouts, 429 and 5xx responses, with backoff (URLs only)")
+	reportCmd.Flags().StringArrayVar(&reportSitemap, "sitemap", nil, "read pages from this sitemap URL or local file instead of discovering sitemaps (repeatable, URLs only)")
+	reportCmd.Flags().StringVar(&reportSince, "since", "", "only crawl sitemap pages modified since this date, or \"last\" for the previous recorded run (URLs only)")
+	addScopeFlags(reportCmd)
+	reportCmd.Flags().StringVar(&outputFormat, "format", "text", "report format: text, json, html, csv, tsv or jsonl (one row per page)")
+	reportCmd.Flags().StringVarP(&reportOutput, "output", "o", "", "write the report to a file instead of stdout")
+	reportCmd.Flags().BoolVar(&reportNoHistory, "no-history", false, "don't record this run for the trend command")
+	reportCmd.Flags().StringVar(&stateDir, "state-dir", "", "history and crawl state directory (default $XDG_STATE_HOME/slopsquid)")
+	reportCmd.Flags().BoolVar(&reportFresh, "fresh", false, "discard saved crawl state: don't resume, and refetch every page (URLs only)")
+	reportCmd.Flags().StringVar(&reportMirror, "mirror", "", "crawl the directory offline as a wget --mirror of the site; --mirror=URL names the site when the directory isn't named after its host")
+	reportCmd.Flags().Lookup("mirror").NoOptDefVal = mirrorFromDir
 
 	rootCmd.AddCommand(scanCmd)
 	rootCmd.AddCommand(scoreCmd)
 	rootCmd.AddCommand(presetsCmd)
 	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(trendCmd)
+	rootCmd.AddCommand(compareCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(watchCmd)
 }
 
 var presetsCmd = &cobra.Command{
@@ -119,17 +202,70 @@ func detectorOpts() detector.DetectorOptions {
 	}
 }
 
-func getDetectorAndFiles(targets []string) (*detector.Detector, []*scanner.FileInfo, error) {
-	d, err := detector.NewDetectorWithOptions(detectorOpts())
+// loadConfig merges .slopsquid.json into the discovery flags. Flags add to
+// the config's globs; --no-ignore in either place turns ignore files off.
+func loadConfig() error {
+	path := configPath
+	if path == "" {
+		var ok bool
+		if path, ok = config.Find("."); !ok {
+			return nil
+		}
+	}
+	cfg, err := config.Load(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("initializing detector: %w", err)
+		return err
+	}
+	if verbose {
+		fmt.Fprintf(os.Stderr, "using config %s\n", cfg.Path())
+	}
+	includeGlobs = append(cfg.Include, includeGlobs...)
+	excludeGlobs = append(cfg.Exclude, excludeGlobs...)
+	noIgnore = noIgnore || cfg.NoIgnore
+	crawlHosts = append(cfg.Crawl.Hosts, crawlHosts...)
+	crawlIncludePaths = append(cfg.Crawl.IncludePaths, crawlIncludePaths...)
+	crawlExcludePaths = append(cfg.Crawl.ExcludePaths, crawlExcludePaths...)
+	crawlIncludeURLs = append(cfg.Crawl.IncludeURLs, crawlIncludeURLs...)
+	crawlExcludeURLs = append(cfg.Crawl.ExcludeURLs, crawlExcludeURLs...)
+	return nil
+}
+
+// addScopeFlags registers the crawl scope flags on a command that crawls
+func addScopeFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayVar(&crawlHosts, "host", nil, "also crawl this host; *.example.com matches subdomains (repeatable, URLs only)")
+	cmd.Flags().StringArrayVar(&crawlIncludePaths, "include-path", nil, "only crawl URLs whose path starts with this prefix (repeatable, URLs only)")
+	cmd.Flags().StringArrayVar(&crawlExcludePaths, "exclude-path", nil, "skip URLs whose path starts with this prefix (repeatable, URLs only)")
+	cmd.Flags().StringArrayVar(&crawlIncludeURLs, "include-url", nil, "only crawl URLs matching this regular expression (repeatable, URLs only)")
+	cmd.Flags().StringArrayVar(&crawlExcludeURLs, "exclude-url", nil, "skip URLs matching this regular expression (repeatable, URLs only)")
+}
+
+func crawlScope() crawler.Scope {
+	return crawler.Scope{
+		Hosts:        crawlHosts,
+		IncludePaths: crawlIncludePaths,
+		ExcludePaths: crawlExcludePaths,
+		Include:      crawlIncludeURLs,
+		Exclude:      crawlExcludeURLs,
 	}
+}
 
-	scanOptions := scanner.ScanOptions{
+func scanOptions() scanner.ScanOptions {
+	return scanner.ScanOptions{
 		Recursive:   recursive,
 		MaxFileSize: 10 * 1024 * 1024,
+		Include:     includeGlobs,
+		Exclude:     excludeGlobs,
+		NoIgnore:    noIgnore,
 	}
-	fileScanner := scanner.NewScanner(scanOptions)
+}
+
+func getDetectorAndFiles(targets []string) (*detector.Detector, []*scanner.FileInfo, error) {
+	d, err := detector.NewDetectorWithOptions(detectorOpts())
+	if err != nil {
+		return nil, nil, fmt.Errorf("initializing detector: %w", err)
+	}
+
+	fileScanner := scanner.NewScanner(scanOptions())
 
 	files, err := fileScanner.ScanTargets(targets)
 	if err != nil {
@@ -144,11 +280,24 @@ func getDetectorAndFiles(targets []string) (*detector.Detector, []*scanner.FileI
 }
 
 func runScan(targets []string) error {
+	format, err := resolveFormat("text", "json", "csv", "tsv", "jsonl")
+	if err != nil {
+		return err
+	}
+
 	d, files, err := getDetectorAndFiles(targets)
 	if err != nil {
 		return err
 	}
 
+	// Row formats stream each file's hits as soon as it is scanned
+	var rows *rowWriter
+	if isRowFormat(format) {
+		if rows, err = newRowWriter(os.Stdout, format, hitColumns); err != nil {
+			return err
+		}
+	}
+
 	type fileResult struct {
 		Path   string               `json:"path"`
 		Result *detector.ScanResult `json:"result"`
@@ -156,6 +305,8 @@ func runScan(targets []string) error {
 
 	var results []fileResult
 	totalHits := 0
+	sc := openScanCache(d)
+	defer reportCacheStats(sc)
 
 	for _, file := range files {
 		if file.Error != "" {
@@ -168,16 +319,30 @@ func runScan(targets []string) error {
 			continue
 		}
 
-		result := d.Scan(file.Content)
+		result := scanCached(d, sc, file.Content)
 		result.Path = file.Path
 
-		if len(result.Hits) > 0 {
+		if rows != nil {
+			for _, h := range result.Hits {
+				rec := hitRecord{Path: file.Path, Hit: h}
+				if err := rows.write(rec, rec.row()); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(result.Hits) > 0 || (verbose && len(result.Suppressed) > 0) {
 			results = append(results, fileResult{Path: file.Path, Result: result})
 			totalHits += len(result.Hits)
 		}
 	}
 
-	if jsonOut {
+	if rows != nil {
+		return nil
+	}
+
+	if format == "json" {
 		data, _ := json.MarshalIndent(results, "", "  ")
 		fmt.Println(string(data))
 		return nil
@@ -193,8 +358,21 @@ func runScan(targets []string) error {
 		return results[i].Result.Score > results[j].Result.Score
 	})
 
-	for _, r := range results {
-		printScanResult(r.Path, r.Result)
+	switch groupBy {
+	case "rule", "severity":
+		scanned := make([]*detector.ScanResult, len(results))
+		for i, r := range results {
+			scanned[i] = r.Result
+		}
+		if groupBy == "rule" {
+			printByRule(scanned)
+		} else {
+			printBySeverity(scanned)
+		}
+	default:
+		for _, r := range results {
+			printScanResult(r.Path, r.Result)
+		}
 	}
 
 	fmt.Printf("\n%d files scanned, %d with hits, %d total detections\n",
@@ -204,36 +382,46 @@ func runScan(targets []string) error {
 }
 
 func runScore(targets []string) error {
+	format, err := resolveFormat("text", "json", "csv", "tsv", "jsonl")
+	if err != nil {
+		return err
+	}
+
 	d, files, err := getDetectorAndFiles(targets)
 	if err != nil {
 		return err
 	}
 
-	type scoreEntry struct {
-		Path    string  `json:"path"`
-		Score   float64 `json:"score"`
-		Rating  string  `json:"rating"`
-		Hits    int     `json:"hits"`
-		Words   int     `json:"words"`
-		Density float64 `json:"density"`
+	// Row formats stream in scan order instead of sorting by score
+	var rows *rowWriter
+	if isRowFormat(format) {
+		if rows, err = newRowWriter(os.Stdout, format, scoreColumns); err != nil {
+			return err
+		}
 	}
 
 	var entries []scoreEntry
+	sc := openScanCache(d)
+	defer reportCacheStats(sc)
 
 	for _, file := range files {
 		if file.Error != "" || len(file.Content) < 20 {
 			continue
 		}
 
-		result := d.Scan(file.Content)
-		entries = append(entries, scoreEntry{
-			Path:    file.Path,
-			Score:   result.Score,
-			Rating:  result.Rating,
-			Hits:    len(result.Hits),
-			Words:   result.WordCount,
-			Density: result.Density,
-		})
+		result := scanCached(d, sc, file.Content)
+		entry := newScoreEntry(file.Path, result)
+		if rows != nil {
+			if err := rows.write(entry, entry.row()); err != nil {
+				return err
+			}
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	if rows != nil {
+		return nil
 	}
 
 	// Sort by score descending
@@ -241,7 +429,7 @@ func runScore(targets []string) error {
 		return entries[i].Score > entries[j].Score
 	})
 
-	if jsonOut {
+	if format == "json" {
 		data, _ := json.MarshalIndent(entries, "", "  ")
 		fmt.Println(string(data))
 		return nil
@@ -318,133 +506,456 @@ func isURL(target string) bool {
 }
 
 func runReport(target string) error {
-	if isURL(target) {
-		return runReportHTTP(target)
+	format, err := resolveFormat("text", "json", "html", "csv", "tsv", "jsonl")
+	if err != nil {
+		return err
+	}
+
+	// Row formats stream each page as it is scored, so their output is
+	// opened first; the others are written once the report is complete
+	var w io.Writer
+	var closeOutput func() error
+	var sink resultSink
+	if isRowFormat(format) {
+		if w, closeOutput, err = createReportOutput(); err != nil {
+			return err
+		}
+		defer closeOutput()
+		rows, err := newRowWriter(w, format, scoreColumns)
+		if err != nil {
+			return err
+		}
+		sink = rowSink(rows)
+	}
+
+	rep, err := buildReport(target, sink)
+	if err != nil {
+		return err
+	}
+
+	if !reportNoHistory {
+		if err := recordRun(rep); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: not recorded in history: %v\n", err)
+		}
+	}
+
+	if sink == nil {
+		if w, closeOutput, err = createReportOutput(); err != nil {
+			return err
+		}
+		defer closeOutput()
+		if err := writeReport(w, format, rep); err != nil {
+			return err
+		}
+	}
+	if err := closeOutput(); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	if w != os.Stdout {
+		fmt.Fprintf(os.Stderr, "report written to %s\n", reportOutput)
+	}
+	return nil
+}
+
+// buildReport crawls a URL or a saved capture of a site, or scans a
+// directory. sink, when set, gets each page as it is scored.
+func buildReport(target string, sink resultSink) (*siteReport, error) {
+	switch {
+	case archive.IsArchive(target):
+		return runReportArchive(target, sink)
+	case reportMirror != "":
+		return runReportMirror(target, sink)
+	case isURL(target):
+		return runReportHTTP(target, sink)
+	}
+	return runReportLocal(target, sink)
+}
+
+// mirrorFromDir is --mirror given without a URL: the site is the host the
+// directory is named after, as wget names it
+const mirrorFromDir = "from-dir"
+
+// runReportArchive crawls a WARC or HAR file offline, from its first page
+func runReportArchive(path string, sink resultSink) (*siteReport, error) {
+	arc, err := archive.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if arc.Root() == "" {
+		return nil, fmt.Errorf("%s: no HTML pages to start crawling from", path)
+	}
+	fmt.Fprintf(os.Stderr, "reading %s: %d archived responses\n", path, arc.Len())
+	return crawlReport(arc.Root(), arc, path, sink)
+}
+
+// runReportMirror crawls a wget --mirror directory offline
+func runReportMirror(dir string, sink resultSink) (*siteReport, error) {
+	site := reportMirror
+	if site == mirrorFromDir {
+		site = ""
+	} else if !strings.HasPrefix(site, "http") {
+		site = "https://" + site
+	}
+	arc, err := archive.OpenMirror(dir, site)
+	if err != nil {
+		return nil, err
 	}
-	return runReportLocal(target)
+	return crawlReport(arc.Root(), arc, dir, sink)
 }
 
-func runReportHTTP(rootURL string) error {
+func runReportHTTP(rootURL string, sink resultSink) (*siteReport, error) {
 	if !strings.HasPrefix(rootURL, "http") {
 		rootURL = "https://" + rootURL
 	}
+	return crawlReport(rootURL, nil, "", sink)
+}
+
+// crawlReport crawls rootURL, over the network or, when arc is set, from
+// the capture at source. Offline crawls keep no state and don't wait
+// between requests; pages keep their original URLs either way.
+func crawlReport(rootURL string, arc *archive.Archive, source string, sink resultSink) (*siteReport, error) {
+	started := time.Now()
 
 	d, err := detector.NewDetectorWithOptions(detectorOpts())
 	if err != nil {
-		return fmt.Errorf("initializing detector: %w", err)
+		return nil, fmt.Errorf("initializing detector: %w", err)
+	}
+
+	since, err := parseSince(reportSince, rootURL)
+	if err != nil {
+		return nil, err
 	}
 
-	c, err := crawler.New(rootURL, crawler.Options{
+	opts := crawler.Options{
 		MaxDepth:    reportDepth,
 		MaxPages:    reportMax,
 		Concurrency: reportWorkers,
 		Delay:       time.Duration(reportDelay) * time.Millisecond,
+		Burst:       reportBurst,
+		Retries:     retriesOption(reportRetries),
 		Verbose:     verbose,
-	})
+		Sitemaps:    reportSitemap,
+		Since:       since,
+		Scope:       crawlScope(),
+	}
+	if arc != nil {
+		opts.Transport = arc
+		opts.Delay = -1
+		opts.Retries = -1
+	} else if opts.State, err = openCrawlState(rootURL); err != nil {
+		return nil, err
+	}
+	state := opts.State
+
+	// Pages are scored as the crawl finishes them, so row formats stream
+	var results []crawlResult
+	var skipped []skippedPage
+	var sinkErr error
+	var cancelCrawl context.CancelFunc
+	totalWords := 0
+	totalHits := 0
+	sc := openScanCache(d)
+	defer reportCacheStats(sc)
+
+	opts.OnPage = func(page *crawler.Page) {
+		if page.Error != "" {
+			reason := page.Error
+			if page.Retries > 0 {
+				reason += fmt.Sprintf(" (after %d retries)", page.Retries)
+			}
+			skipped = append(skipped, skippedPage{URL: page.URL, Reason: reason})
+			return
+		}
+		if len(strings.Fields(page.Text)) < crawler.MinWords {
+			skipped = append(skipped, skippedPage{URL: page.URL, Reason: fmt.Sprintf("fewer than %d words", crawler.MinWords)})
+			return
+		}
+
+		result := scanCached(d, sc, page.Text)
+		result.Path = page.URL
+		totalWords += result.WordCount
+		totalHits += len(result.Hits)
+
+		// Aliases are read when the report is written; a streamed row has
+		// the ones known so far
+		res := crawlResult{URL: page.URL, Aliases: page.Aliases, Retries: page.Retries, Result: result}
+		results = append(results, res)
+		if sink != nil && sinkErr == nil {
+			// Nothing more can be written, so stop fetching
+			if sinkErr = sink(res); sinkErr != nil {
+				cancelCrawl()
+			}
+		}
+	}
+
+	c, err := crawler.New(rootURL, opts)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	if arc != nil {
+		fmt.Fprintf(os.Stderr, "crawling %s offline from %s ...\n", rootURL, source)
+	} else {
+		fmt.Fprintf(os.Stderr, "crawling %s ...\n", rootURL)
+	}
+	if c.RobotsBlocked() {
+		fmt.Fprintf(os.Stderr, "warning: robots.txt is unreachable, so no pages may be crawled\n")
+	}
+	if arc == nil && c.Delay() > time.Duration(reportDelay)*time.Millisecond {
+		fmt.Fprintf(os.Stderr, "  robots.txt asks for %s between requests\n", c.Delay())
+	}
+	if done, queued := state.Resuming(); done+queued > 0 {
+		fmt.Fprintf(os.Stderr, "  resuming: %d pages already fetched, %d queued (--fresh starts over)\n", done, queued)
+	} else if n := state.Previous(); n > 0 {
+		fmt.Fprintf(os.Stderr, "  %d pages from the last crawl are requested only if changed\n", n)
 	}
 
-	fmt.Fprintf(os.Stderr, "crawling %s ...\n", rootURL)
+	// The first Ctrl-C stops the crawl and reports what was fetched; once
+	// the handler is released a second one kills the process as usual
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	ctx, cancelCrawl = context.WithCancel(ctx)
+	defer cancelCrawl()
 
-	pages, err := c.Crawl(func(n int, url string) {
+	fetched := 0
+	pages, err := c.Crawl(ctx, func(n int, url string) {
+		fetched = n
 		if verbose {
 			fmt.Fprintf(os.Stderr, "  [%d] %s\n", n, url)
 		} else {
 			fmt.Fprintf(os.Stderr, "\r  %d pages fetched", n)
 		}
 	})
+	if sinkErr != nil {
+		return nil, sinkErr
+	}
+	partial := false
 	if err != nil {
-		return err
+		if ctx.Err() == nil {
+			return nil, err
+		}
+		partial = true
+	}
+	fmt.Fprintf(os.Stderr, "\r  %d pages fetched\n", fetched)
+	if n := retriedPages(pages); n > 0 {
+		fmt.Fprintf(os.Stderr, "  %d pages needed retries\n", n)
+	}
+	if n := notModified(pages); n > 0 {
+		fmt.Fprintf(os.Stderr, "  %d pages unchanged since the last crawl\n", n)
+	}
+	if err := state.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: crawl state not saved: %v\n", err)
+	}
+	if n := aliasCount(pages); n > 0 {
+		fmt.Fprintf(os.Stderr, "  %d duplicate URLs folded into the pages they repeat\n", n)
+	}
+	if partial {
+		fmt.Fprintf(os.Stderr, "  interrupted, reporting the pages fetched so far\n")
+	}
+	if n := c.Unchanged(); n > 0 {
+		fmt.Fprintf(os.Stderr, "  %d sitemap pages unchanged since %s, not fetched and not in the crawl state\n", n, since.Format("2006-01-02 15:04"))
 	}
-	fmt.Fprintf(os.Stderr, "\r  %d pages fetched\n", len(pages))
 
-	var results []crawlResult
-	var skipped int
-	totalWords := 0
-	totalHits := 0
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Result.Score > results[j].Result.Score
+	})
 
-	for _, page := range pages {
-		if page.Error != "" || len(strings.Fields(page.Text)) < 10 {
-			skipped++
-			continue
+	return &siteReport{
+		Source:       rootURL,
+		Mode:         "crawl",
+		Results:      results,
+		TotalPages:   len(pages),
+		SkippedPages: skipped,
+		NotCrawled:   notCrawled(c.Skipped()),
+		TotalWords:   totalWords,
+		TotalHits:    totalHits,
+		RuleSetHash:  d.RuleSetHash(),
+		StartedAt:    started,
+		FinishedAt:   time.Now(),
+		Crawl: &crawlSettings{
+			MaxDepth:    reportDepth,
+			MaxPages:    reportMax,
+			DelayMillis: int(c.Delay() / time.Millisecond),
+			Workers:     reportWorkers,
+			Burst:       reportBurst,
+			Retries:     reportRetries,
+			Partial:     partial,
+			Since:       formatSince(since),
+			Unchanged:   c.Unchanged(),
+			Scope:       scopeSettings(),
+			Archive:     source,
+		},
+	}, nil
+}
+
+// retriesOption maps --retries to crawler.Options: 0 turns retries off on the
+// command line, but means "use the default" to the crawler
+func retriesOption(n int) int {
+	if n <= 0 {
+		return -1
+	}
+	return n
+}
+
+func retriedPages(pages []*crawler.Page) int {
+	n := 0
+	for _, p := range pages {
+		if p.Retries > 0 {
+			n++
 		}
+	}
+	return n
+}
 
-		result := d.Scan(page.Text)
-		result.Path = page.URL
-		totalWords += result.WordCount
-		totalHits += len(result.Hits)
+// openCrawlState opens the saved crawl of rootURL with the current scope
+func openCrawlState(rootURL string) (*crawler.State, error) {
+	dir, err := resolveStateDir()
+	if err != nil {
+		return nil, err
+	}
+	return crawler.OpenState(crawler.StateDir(dir, rootURL, crawlScope()), reportFresh)
+}
 
-		results = append(results, crawlResult{URL: page.URL, Result: result})
+func notModified(pages []*crawler.Page) int {
+	n := 0
+	for _, p := range pages {
+		if p.NotModified {
+			n++
+		}
 	}
+	return n
+}
 
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Result.Score > results[j].Result.Score
-	})
+// notCrawled converts the crawler's skipped URLs for the report
+func notCrawled(skips []crawler.Skip) []skippedPage {
+	var out []skippedPage
+	for _, s := range skips {
+		out = append(out, skippedPage{URL: s.URL, Reason: s.Reason})
+	}
+	return out
+}
 
-	if jsonOut {
-		data, _ := json.MarshalIndent(results, "", "  ")
-		fmt.Println(string(data))
+// scopeSettings records the crawl scope, or nil when it is the default
+func scopeSettings() *crawlScopeSettings {
+	s := &crawlScopeSettings{
+		Hosts:        crawlHosts,
+		IncludePaths: crawlIncludePaths,
+		ExcludePaths: crawlExcludePaths,
+		IncludeURLs:  crawlIncludeURLs,
+		ExcludeURLs:  crawlExcludeURLs,
+	}
+	if len(s.Hosts)+len(s.IncludePaths)+len(s.ExcludePaths)+len(s.IncludeURLs)+len(s.ExcludeURLs) == 0 {
 		return nil
 	}
+	return s
+}
 
-	printCrawlReport(rootURL, results, len(pages), skipped, totalWords, totalHits)
-	return nil
+func aliasCount(pages []*crawler.Page) int {
+	n := 0
+	for _, p := range pages {
+		n += len(p.Aliases)
+	}
+	return n
 }
 
-func runReportLocal(target string) error {
+// formatSince records --since in a report, or "" when it wasn't given
+func formatSince(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// parseSince reads --since: a date, an RFC 3339 time, or "last" for the
+// time the previous report of source was recorded
+func parseSince(value, source string) (time.Time, error) {
+	switch value {
+	case "":
+		return time.Time{}, nil
+	case "last":
+		store, err := openHistory()
+		if err != nil {
+			return time.Time{}, err
+		}
+		runs, err := store.Runs(source)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(runs) == 0 {
+			return time.Time{}, fmt.Errorf("--since last: no recorded report for %s", source)
+		}
+		return runs[len(runs)-1].RunAt, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid --since %q (want YYYY-MM-DD, an RFC 3339 time, or \"last\")", value)
+}
+
+func runReportLocal(target string, sink resultSink) (*siteReport, error) {
 	absTarget, _ := filepath.Abs(target)
+	started := time.Now()
 
 	d, err := detector.NewDetectorWithOptions(detectorOpts())
 	if err != nil {
-		return fmt.Errorf("initializing detector: %w", err)
+		return nil, fmt.Errorf("initializing detector: %w", err)
 	}
 
-	scanOptions := scanner.ScanOptions{
-		Recursive:   true,
-		MaxFileSize: 10 * 1024 * 1024,
-	}
-	fileScanner := scanner.NewScanner(scanOptions)
+	fileScanner := scanner.NewScanner(scanOptions())
 
 	files, err := fileScanner.ScanTargets([]string{target})
 	if err != nil {
-		return fmt.Errorf("scanning targets: %w", err)
+		return nil, fmt.Errorf("scanning targets: %w", err)
 	}
 
 	fmt.Fprintf(os.Stderr, "scanning %s ...\n", absTarget)
 
 	var results []crawlResult
-	var skipped int
+	var skipped []skippedPage
 	totalWords := 0
 	totalHits := 0
+	sc := openScanCache(d)
+	defer reportCacheStats(sc)
 
 	for _, file := range files {
 		if file.Error != "" {
-			skipped++
+			skipped = append(skipped, skippedPage{URL: file.Path, Reason: file.Error})
 			continue
 		}
 
 		text := file.Content
 		// For HTML files, use the crawler's text extractor for consistency
 		ext := strings.ToLower(filepath.Ext(file.Path))
-		if ext == ".html" || ext == ".htm" {
+		if ext == ".html" || ext == ".htm" || ext == ".xhtml" {
 			raw, readErr := os.ReadFile(file.Path)
 			if readErr == nil {
 				text = crawler.ExtractText(string(raw))
 			}
 		}
 
-		if len(strings.Fields(text)) < 10 {
-			skipped++
+		if len(strings.Fields(text)) < crawler.MinWords {
+			skipped = append(skipped, skippedPage{URL: file.Path, Reason: fmt.Sprintf("fewer than %d words", crawler.MinWords)})
 			continue
 		}
 
-		result := d.Scan(text)
+		result := scanCached(d, sc, text)
 		result.Path = file.Path
 		totalWords += result.WordCount
 		totalHits += len(result.Hits)
 
-		results = append(results, crawlResult{URL: file.Path, Result: result})
+		res := crawlResult{URL: file.Path, Result: result}
+		results = append(results, res)
+		if sink != nil {
+			if err := sink(res); err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	fmt.Fprintf(os.Stderr, "  %d files processed\n", len(files))
@@ -453,12 +964,16 @@ func runReportLocal(target string) error {
 		return results[i].Result.Score > results[j].Result.Score
 	})
 
-	if jsonOut {
-		data, _ := json.MarshalIndent(results, "", "  ")
-		fmt.Println(string(data))
-		return nil
-	}
-
-	printCrawlReport(absTarget, results, len(files), skipped, totalWords, totalHits)
-	return nil
+	return &siteReport{
+		Source:       absTarget,
+		Mode:         "local",
+		Results:      results,
+		TotalPages:   len(files),
+		SkippedPages: skipped,
+		TotalWords:   totalWords,
+		TotalHits:    totalHits,
+		RuleSetHash:  d.RuleSetHash(),
+		StartedAt:    started,
+		FinishedAt:   time.Now(),
+	}, nil
 }
@@ -2,19 +2,24 @@ package main
 
 import (
 	"fmt"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/QRY91/slopsquid/internal/detector"
 )
 
 type crawlResult struct {
-	URL    string               `json:"url"`
-	Result *detector.ScanResult `json:"result"`
+	URL     string               `json:"url"`
+	Aliases []string             `json:"aliases,omitempty"` // other URLs serving the same page
+	Retries int                  `json:"retries,omitempty"` // fetch attempts after the first
+	Result  *detector.ScanResult `json:"result"`
 }
 
 func printScanResult(path string, result *detector.ScanResult) {
 	icon := ratingIcon(result.Rating)
-	fmt.Printf("\n%s %s — score: %.0f/100 (%s)\n", icon, path, result.Score, result.Rating)
+	fmt.Printf("\n%s %s — score: %.0f/100 (%s)\n", icon, paint("bold", path), result.Score, paint(result.Rating, result.Rating))
 
 	// Group hits by type for cleaner output
 	var words, trigrams, patterns []detector.Hit
@@ -39,27 +44,42 @@ func printScanResult(path string, result *detector.ScanResult) {
 		printHitGroup("patterns", patterns)
 	}
 
+	if verbose {
+		for _, h := range result.Suppressed {
+			fmt.Println(paint("dim", wrap(fmt.Sprintf("  [--] line %d: %q — suppressed by context rule: %s",
+				h.Line, h.Match, h.SuppressedBy), 7)))
+		}
+	}
+
 	fmt.Printf("  %d hits in %d lines, %d words — density: %.1f per 1k words\n",
 		len(result.Hits), result.LineCount, result.WordCount, result.Density)
 }
 
 func printHitGroup(label string, hits []detector.Hit) {
-	// Deduplicate by match text, count occurrences
+	// Deduplicate by match text (or stem family), count occurrences
 	type matchInfo struct {
 		hit   detector.Hit
 		count int
 		lines []int
+		forms []string
 	}
 	seen := make(map[string]*matchInfo)
 	var order []string
 
 	for _, h := range hits {
 		key := strings.ToLower(h.Match)
+		if h.Family != "" {
+			key = "family:" + strings.ToLower(h.Family)
+		}
+		form := strings.Join(strings.Fields(strings.ToLower(h.Match)), " ")
 		if info, ok := seen[key]; ok {
 			info.count++
 			info.lines = append(info.lines, h.Line)
+			if !containsString(info.forms, form) {
+				info.forms = append(info.forms, form)
+			}
 		} else {
-			seen[key] = &matchInfo{hit: h, count: 1, lines: []int{h.Line}}
+			seen[key] = &matchInfo{hit: h, count: 1, lines: []int{h.Line}, forms: []string{form}}
 			order = append(order, key)
 		}
 	}
@@ -69,25 +89,53 @@ func printHitGroup(label string, hits []detector.Hit) {
 		h := info.hit
 
 		sevTag := severityTag(h.Severity)
+		match := fmt.Sprintf("%q", h.Match)
+		if h.Family != "" {
+			match = fmt.Sprintf("%q", h.Family)
+			if len(info.forms) > 1 || info.forms[0] != strings.ToLower(h.Family) {
+				match += " (" + strings.Join(info.forms, ", ") + ")"
+			}
+		}
 
 		if info.count > 1 {
-			fmt.Printf("  %s line %d (+%d): %q — %s\n",
-				sevTag, info.lines[0], info.count-1, h.Match, h.Detail)
+			fmt.Println(wrap(fmt.Sprintf("  %s line %d (+%d): %s — %s",
+				sevTag, info.lines[0], info.count-1, match, h.Detail), 7))
 		} else {
-			fmt.Printf("  %s line %d: %q — %s\n",
-				sevTag, h.Line, h.Match, h.Detail)
+			fmt.Println(wrap(fmt.Sprintf("  %s line %d: %s — %s",
+				sevTag, h.Line, match, h.Detail), 7))
+		}
+		if h.Snippet != nil {
+			fmt.Println(wrap("       "+formatSnippet(h.Snippet, h.Severity), 7))
+		}
+	}
+}
+
+// formatSnippet renders a hit in context, highlighting the match in its
+// severity color, or in brackets when color is off
+func formatSnippet(sn *detector.Snippet, severity string) string {
+	if !colorOn {
+		return sn.Before + "[" + sn.Match + "]" + sn.After
+	}
+	return paint("dim", sn.Before) + paint(severity, sn.Match) + paint("dim", sn.After)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
 		}
 	}
+	return false
 }
 
 func severityTag(severity string) string {
 	switch severity {
 	case "high":
-		return "[!!]"
+		return paint(severity, "[!!]")
 	case "medium":
-		return "[! ]"
+		return paint(severity, "[! ]")
 	case "low":
-		return "[  ]"
+		return paint(severity, "[  ]")
 	default:
 		return "[  ]"
 	}
@@ -96,133 +144,191 @@ func severityTag(severity string) string {
 func ratingIcon(rating string) string {
 	switch rating {
 	case "clean":
-		return "."
+		return paint(rating, ".")
 	case "moderate":
-		return "*"
+		return paint(rating, "*")
 	case "heavy":
-		return "!"
+		return paint(rating, "!")
 	default:
 		return "?"
 	}
 }
 
-func printCrawlReport(source string, results []crawlResult, totalPages, skipped, totalWords, totalHits int) {
-	fmt.Printf("\n== SlopSquid Report ==\n")
-	fmt.Printf("   Source: %s\n", source)
-	fmt.Printf("   Files scanned: %d (%d skipped)\n", totalPages, skipped)
-	fmt.Printf("   Files scored: %d\n", len(results))
-	fmt.Printf("   Total words: %d\n", totalWords)
-	fmt.Printf("   Total hits: %d\n\n", totalHits)
-
-	// Aggregate stats
-	var clean, moderate, heavy int
-	var totalScore float64
-	for _, r := range results {
-		totalScore += r.Result.Score
-		switch r.Result.Rating {
-		case "clean":
-			clean++
-		case "moderate":
-			moderate++
-		case "heavy":
-			heavy++
-		}
+func printCrawlReport(w io.Writer, rep *siteReport) {
+	fmt.Fprintf(w, "\n== SlopSquid Report ==\n")
+	fmt.Fprintf(w, "   Source: %s\n", rep.Source)
+	if rep.Crawl != nil && rep.Crawl.Archive != "" {
+		fmt.Fprintf(w, "   Archive: %s (offline)\n", rep.Crawl.Archive)
 	}
-
-	avgScore := 0.0
-	if len(results) > 0 {
-		avgScore = totalScore / float64(len(results))
+	if rep.Crawl != nil && rep.Crawl.Partial {
+		fmt.Fprintf(w, "   %s\n", paint("moderate", "Partial: crawl was interrupted"))
 	}
+	fmt.Fprintf(w, "   Files scanned: %d (%d skipped)\n", rep.TotalPages, len(rep.SkippedPages))
+	fmt.Fprintf(w, "   Files scored: %d\n", len(rep.Results))
+	if n := len(rep.NotCrawled); n > 0 {
+		fmt.Fprintf(w, "   Not crawled: %d URLs\n", n)
+	}
+	fmt.Fprintf(w, "   Total words: %d\n", rep.TotalWords)
+	fmt.Fprintf(w, "   Total hits: %d\n\n", rep.TotalHits)
+
+	sum := summarize(rep.Results)
 
-	fmt.Printf("   Breakdown: %d clean, %d moderate, %d heavy\n", clean, moderate, heavy)
-	fmt.Printf("   Average score: %.1f/100\n\n", avgScore)
+	fmt.Fprintf(w, "   Breakdown: %d clean, %d moderate, %d heavy\n", sum.Clean, sum.Moderate, sum.Heavy)
+	fmt.Fprintf(w, "   Average score: %.1f/100\n\n", sum.AverageScore)
 
 	// Top offenders (pages with hits, sorted by score desc — already sorted)
-	fmt.Printf("-- Per-page scores --\n")
-	for _, r := range results {
+	fmt.Fprintf(w, "-- Per-page scores --\n")
+	for _, r := range rep.Results {
 		icon := ratingIcon(r.Result.Rating)
-		// Shorten URL for display
-		path := r.URL
-		if idx := strings.Index(path, "://"); idx != -1 {
-			after := path[idx+3:]
-			if slashIdx := strings.Index(after, "/"); slashIdx != -1 {
-				path = after[slashIdx:]
-			}
+		path := rep.displayURL(r.URL)
+		switch n := len(r.Aliases); {
+		case n == 1:
+			path += paint("dim", " (+1 alias)")
+		case n > 1:
+			path += paint("dim", fmt.Sprintf(" (+%d aliases)", n))
 		}
-		fmt.Printf("%s %5.1f  %-8s  %3d hits  %5d words  %s\n",
+		fmt.Fprintf(w, "%s %5.1f  %-8s  %3d hits  %5d words  %s\n",
 			icon, r.Result.Score, r.Result.Rating, len(r.Result.Hits), r.Result.WordCount, path)
 	}
 
 	// Top hits across entire site
-	type siteHit struct {
-		match    string
-		hitType  string
-		severity string
-		detail   string
-		count    int
-		pages    []string
+	if len(sum.TopHits) > 0 {
+		fmt.Fprintf(w, "\n-- Most frequent hits across site --\n")
+		limit := 15
+		if len(sum.TopHits) < limit {
+			limit = len(sum.TopHits)
+		}
+		for _, sh := range sum.TopHits[:limit] {
+			fmt.Fprintf(w, "  %s %dx across %d pages: %q — %s\n",
+				severityTag(sh.Severity), sh.Count, len(sh.Pages), sh.Match, sh.Detail)
+		}
+	}
+
+	if len(rep.SkippedPages) > 0 {
+		fmt.Fprintf(w, "\n-- Skipped --\n")
+		for _, s := range rep.SkippedPages {
+			fmt.Fprintf(w, "  %s %s\n", rep.displayURL(s.URL), paint("dim", "— "+s.Reason))
+		}
+	}
+	if len(rep.NotCrawled) > 0 {
+		printNotCrawled(w, rep)
 	}
-	hitMap := make(map[string]*siteHit)
-	var hitOrder []string
+
+	fmt.Fprintln(w)
+}
+
+// printNotCrawled groups the URLs a crawl found but did not fetch by reason,
+// with a few examples of each (all of them with --verbose)
+func printNotCrawled(w io.Writer, rep *siteReport) {
+	var reasons []string
+	byReason := make(map[string][]string)
+	for _, s := range rep.NotCrawled {
+		if _, ok := byReason[s.Reason]; !ok {
+			reasons = append(reasons, s.Reason)
+		}
+		byReason[s.Reason] = append(byReason[s.Reason], s.URL)
+	}
+	sort.SliceStable(reasons, func(i, j int) bool {
+		return len(byReason[reasons[i]]) > len(byReason[reasons[j]])
+	})
+
+	fmt.Fprintf(w, "\n-- Not crawled --\n")
+	for _, reason := range reasons {
+		urls := byReason[reason]
+		fmt.Fprintf(w, "  %4d  %s\n", len(urls), reason)
+		limit := len(urls)
+		if !verbose && limit > 3 {
+			limit = 3
+		}
+		for _, u := range urls[:limit] {
+			fmt.Fprintf(w, "        %s\n", paint("dim", rep.displayURL(u)))
+		}
+		if limit < len(urls) {
+			fmt.Fprintf(w, "        %s\n", paint("dim", fmt.Sprintf("… %d more (--verbose lists all)", len(urls)-limit)))
+		}
+	}
+}
+
+// printByRule lists every file and line each rule hit, most frequent first,
+// for answering "which files use 'delve'?"
+func printByRule(results []*detector.ScanResult) {
+	type ruleGroup struct {
+		hit   detector.Hit
+		count int
+		files []string
+		lines map[string][]int
+	}
+	groups := make(map[string]*ruleGroup)
+	var order []string
 
 	for _, r := range results {
-		for _, h := range r.Result.Hits {
-			key := strings.ToLower(h.Match)
-			if sh, ok := hitMap[key]; ok {
-				sh.count++
-				// Track unique pages
-				found := false
-				for _, p := range sh.pages {
-					if p == r.URL {
-						found = true
-						break
-					}
-				}
-				if !found {
-					sh.pages = append(sh.pages, r.URL)
-				}
-			} else {
-				hitMap[key] = &siteHit{
-					match:    h.Match,
-					hitType:  h.Type,
-					severity: h.Severity,
-					detail:   h.Detail,
-					count:    1,
-					pages:    []string{r.URL},
-				}
-				hitOrder = append(hitOrder, key)
+		for _, h := range r.Hits {
+			key := h.Type + ":" + strings.ToLower(h.Rule)
+			g, ok := groups[key]
+			if !ok {
+				g = &ruleGroup{hit: h, lines: make(map[string][]int)}
+				groups[key] = g
+				order = append(order, key)
 			}
+			g.count++
+			if _, seen := g.lines[r.Path]; !seen {
+				g.files = append(g.files, r.Path)
+			}
+			g.lines[r.Path] = append(g.lines[r.Path], h.Line)
 		}
 	}
 
-	if len(hitOrder) > 0 {
-		// Sort by count descending
-		type kv struct {
-			key string
-			sh  *siteHit
+	sort.SliceStable(order, func(i, j int) bool {
+		gi, gj := groups[order[i]], groups[order[j]]
+		if gi.count != gj.count {
+			return gi.count > gj.count
 		}
-		var sorted []kv
-		for _, k := range hitOrder {
-			sorted = append(sorted, kv{k, hitMap[k]})
+		return order[i] < order[j]
+	})
+
+	for _, key := range order {
+		g := groups[key]
+		fmt.Println()
+		fmt.Println(wrap(fmt.Sprintf("%s %s (%s) — %s", severityTag(g.hit.Severity),
+			paint("bold", g.hit.Rule), g.hit.Type, g.hit.Detail), 5))
+		fmt.Printf("     %d hits in %d files\n", g.count, len(g.files))
+		for _, path := range g.files {
+			fmt.Println(wrap(fmt.Sprintf("     %s: %s", path, formatLines(g.lines[path])), 7))
 		}
-		for i := 0; i < len(sorted)-1; i++ {
-			for j := i + 1; j < len(sorted); j++ {
-				if sorted[j].sh.count > sorted[i].sh.count {
-					sorted[i], sorted[j] = sorted[j], sorted[i]
+	}
+}
+
+// printBySeverity lists hits under high, medium and low headings
+func printBySeverity(results []*detector.ScanResult) {
+	for _, sev := range []string{"high", "medium", "low"} {
+		var lines []string
+		for _, r := range results {
+			for _, h := range r.Hits {
+				if h.Severity != sev {
+					continue
 				}
+				lines = append(lines, wrap(fmt.Sprintf("  %s:%d:%d %q — %s",
+					r.Path, h.Line, h.Column, h.Match, h.Detail), 6))
 			}
 		}
-
-		fmt.Printf("\n-- Most frequent hits across site --\n")
-		limit := 15
-		if len(sorted) < limit {
-			limit = len(sorted)
+		if len(lines) == 0 {
+			continue
 		}
-		for _, s := range sorted[:limit] {
-			fmt.Printf("  %s %dx across %d pages: %q — %s\n",
-				severityTag(s.sh.severity), s.sh.count, len(s.sh.pages), s.sh.match, s.sh.detail)
+		fmt.Printf("\n%s %s (%d hits)\n", severityTag(sev), paint(sev, sev), len(lines))
+		for _, l := range lines {
+			fmt.Println(l)
 		}
 	}
+}
 
-	fmt.Println()
+// formatLines renders "line 3" or "lines 3, 8, 12"
+func formatLines(lines []int) string {
+	if len(lines) == 1 {
+		return fmt.Sprintf("line %d", lines[0])
+	}
+	parts := make([]string, len(lines))
+	for i, l := range lines {
+		parts[i] = strconv.Itoa(l)
+	}
+	return "lines " + strings.Join(parts, ", ")
 }
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QRY91/slopsquid/internal/detector"
+)
+
+func TestWrap(t *testing.T) {
+	saved := termWidth
+	t.Cleanup(func() { termWidth = saved })
+	termWidth = 40
+
+	snippet := formatSnippet(&detector.Snippet{
+		Before: "the quiet room where the lamp",
+		Match:  "flickered",
+		After:  " and the shadows moved along the wall",
+	}, "high")
+	tests := []struct {
+		name   string
+		s      string
+		indent int
+	}{
+		{"snippet under a hit", "       " + snippet, 7},
+		{"hit line", "  [!!] line 3: \"flickered\" — banlist word, 98.5% of 67 models overuse", 7},
+		{"watch snippet", "         " + snippet, 9},
+	}
+	for _, tt := range tests {
+		lines := strings.Split(wrap(tt.s, tt.indent), "\n")
+		if len(lines) < 2 {
+			t.Errorf("%s: not wrapped: %q", tt.name, lines)
+		}
+		for i, line := range lines {
+			if n := visibleLen(line); n > termWidth {
+				t.Errorf("%s: line %d is %d columns, over %d: %q", tt.name, i, n, termWidth, line)
+			}
+			if i > 0 && !strings.HasPrefix(line, strings.Repeat(" ", tt.indent)) {
+				t.Errorf("%s: line %d not indented: %q", tt.name, i, line)
+			}
+		}
+		if got := strings.Join(strings.Fields(strings.Join(lines, " ")), " "); got != strings.Join(strings.Fields(tt.s), " ") {
+			t.Errorf("%s: words changed: %q", tt.name, got)
+		}
+	}
+
+	termWidth = 0
+	if s := "       " + snippet; wrap(s, 7) != s {
+		t.Error("wrapped without a terminal width")
+	}
+}
+
+func TestAutoColor(t *testing.T) {
+	tests := []struct {
+		tty           bool
+		noColor, term string
+		want          bool
+	}{
+		{true, "", "xterm", true},
+		{true, "1", "xterm", false},
+		{true, "", "dumb", false},
+		{false, "", "xterm", false},
+	}
+	for _, tt := range tests {
+		t.Setenv("NO_COLOR", tt.noColor)
+		t.Setenv("TERM", tt.term)
+		if got := autoColor(tt.tty); got != tt.want {
+			t.Errorf("autoColor(%v) with NO_COLOR=%q TERM=%q = %v, want %v", tt.tty, tt.noColor, tt.term, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/QRY91/slopsquid/internal/detector"
+)
+
+// Column orders are part of the CSV/TSV contract: append new columns at the
+// end, never reorder or rename
+var (
+	hitColumns = []string{
+		"path", "line", "column", "end_line", "end_column",
+		"type", "rule", "match", "severity", "weight", "detail",
+	}
+	scoreColumns = []string{"path", "score", "rating", "hits", "words", "density"}
+)
+
+// scoreEntry is one file's (or page's) summary row
+type scoreEntry struct {
+	Path    string  `json:"path"`
+	Score   float64 `json:"score"`
+	Rating  string  `json:"rating"`
+	Hits    int     `json:"hits"`
+	Words   int     `json:"words"`
+	Density float64 `json:"density"`
+}
+
+func newScoreEntry(path string, result *detector.ScanResult) scoreEntry {
+	return scoreEntry{
+		Path:    path,
+		Score:   result.Score,
+		Rating:  result.Rating,
+		Hits:    len(result.Hits),
+		Words:   result.WordCount,
+		Density: result.Density,
+	}
+}
+
+func (e scoreEntry) row() []string {
+	return []string{
+		e.Path,
+		formatFloat(e.Score),
+		e.Rating,
+		strconv.Itoa(e.Hits),
+		strconv.Itoa(e.Words),
+		formatFloat(e.Density),
+	}
+}
+
+// hitRecord is one hit with the file it came from, flattened for JSONL
+type hitRecord struct {
+	Path string `json:"path"`
+	detector.Hit
+}
+
+func (r hitRecord) row() []string {
+	return []string{
+		r.Path,
+		strconv.Itoa(r.Line),
+		strconv.Itoa(r.Column),
+		strconv.Itoa(r.EndLine),
+		strconv.Itoa(r.EndColumn),
+		r.Type,
+		r.Rule,
+		r.Match,
+		r.Severity,
+		formatFloat(r.Weight),
+		r.Detail,
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// isRowFormat reports whether format is written one record at a time
+func isRowFormat(format string) bool {
+	return format == "csv" || format == "tsv" || format == "jsonl"
+}
+
+// resolveFormat applies --json and checks --format against what a command
+// supports
+func resolveFormat(allowed ...string) (string, error) {
+	if jsonOut {
+		return "json", nil
+	}
+	for _, f := range allowed {
+		if outputFormat == f {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q (want one of %v)", outputFormat, allowed)
+}
+
+// rowWriter streams records as CSV, TSV or JSON Lines, flushing after each
+// so downstream tools see results as soon as a file is done
+type rowWriter struct {
+	csv *csv.Writer
+	tsv io.Writer
+	enc *json.Encoder
+}
+
+// tsvEscaper escapes TSV fields with backslashes, as linear TSV does, so
+// every row stays on one line and tabs inside a field can't split it
+var tsvEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// newRowWriter writes the header row straight away for CSV and TSV, so an
+// empty run still produces the stable column set
+func newRowWriter(w io.Writer, format string, columns []string) (*rowWriter, error) {
+	rw := &rowWriter{}
+	switch format {
+	case "jsonl":
+		rw.enc = json.NewEncoder(w)
+		rw.enc.SetEscapeHTML(false)
+		return rw, nil
+	case "tsv":
+		rw.tsv = w
+		return rw, rw.writeTSV(columns)
+	default:
+		rw.csv = csv.NewWriter(w)
+	}
+	if err := rw.csv.Write(columns); err != nil {
+		return nil, err
+	}
+	rw.csv.Flush()
+	return rw, rw.csv.Error()
+}
+
+// write emits record as a JSON line, or row as a CSV/TSV line
+func (rw *rowWriter) write(record any, row []string) error {
+	if rw.enc != nil {
+		return rw.enc.Encode(record)
+	}
+	if rw.tsv != nil {
+		return rw.writeTSV(row)
+	}
+	if err := rw.csv.Write(row); err != nil {
+		return err
+	}
+	rw.csv.Flush()
+	return rw.csv.Error()
+}
+
+func (rw *rowWriter) writeTSV(fields []string) error {
+	var b strings.Builder
+	for i, f := range fields {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(tsvEscaper.Replace(f))
+	}
+	b.WriteByte('\n')
+	_, err := io.WriteString(rw.tsv, b.String())
+	return err
+}
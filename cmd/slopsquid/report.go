@@ -0,0 +1,409 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// siteReport is a scored corpus (crawled site or local directory) ready for
+// rendering as text, JSON or HTML
+type siteReport struct {
+	Source       string
+	Mode         string // "crawl" or "local"
+	Results      []crawlResult
+	TotalPages   int
+	SkippedPages []skippedPage
+	NotCrawled   []skippedPage // URLs found but not fetched; crawl reports only
+	TotalWords   int
+	TotalHits    int
+	RuleSetHash  string
+	StartedAt    time.Time
+	FinishedAt   time.Time
+	Crawl        *crawlSettings // nil for local reports
+}
+
+// skippedPage is a page or file that was fetched but not scored
+type skippedPage struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// crawlSettings records the crawl flags a URL report ran with
+type crawlSettings struct {
+	MaxDepth    int  `json:"max_depth"`
+	MaxPages    int  `json:"max_pages"`
+	DelayMillis int  `json:"delay_ms"`
+	Workers     int  `json:"workers"`
+	Burst       int  `json:"burst"`
+	Retries     int  `json:"retries"`
+	Partial     bool `json:"partial,omitempty"` // interrupted before the crawl finished
+	// Since is --since: sitemap pages older than it were not fetched, and
+	// were reported from the last crawl's state except for Unchanged of them
+	Since     string `json:"since,omitempty"`
+	Unchanged int    `json:"unchanged,omitempty"`
+
+	Scope *crawlScopeSettings `json:"scope,omitempty"`
+	// Archive is the WARC, HAR or mirror an offline crawl read
+	Archive string `json:"archive,omitempty"`
+}
+
+// crawlScopeSettings records the scope flags and config a crawl ran with
+type crawlScopeSettings struct {
+	Hosts        []string `json:"hosts,omitempty"`
+	IncludePaths []string `json:"include_paths,omitempty"`
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+	IncludeURLs  []string `json:"include_urls,omitempty"`
+	ExcludeURLs  []string `json:"exclude_urls,omitempty"`
+}
+
+// reportSummary holds the aggregates every report format shows
+type reportSummary struct {
+	Clean        int       `json:"clean"`
+	Moderate     int       `json:"moderate"`
+	Heavy        int       `json:"heavy"`
+	AverageScore float64   `json:"average_score"`
+	Histogram    [10]int   `json:"histogram"` // pages per score decile: 0-9, 10-19, ... 90-100
+	TopHits      []siteHit `json:"top_hits"`  // most frequent first
+}
+
+// siteHit is one rule's hits tallied across every page
+type siteHit struct {
+	Match    string   `json:"match"`
+	Type     string   `json:"type"`
+	Severity string   `json:"severity"`
+	Detail   string   `json:"detail"`
+	Count    int      `json:"count"`
+	Pages    []string `json:"pages"`
+}
+
+func summarize(results []crawlResult) reportSummary {
+	var sum reportSummary
+	var totalScore float64
+	hitMap := make(map[string]*siteHit)
+
+	for _, r := range results {
+		totalScore += r.Result.Score
+		switch r.Result.Rating {
+		case "clean":
+			sum.Clean++
+		case "moderate":
+			sum.Moderate++
+		case "heavy":
+			sum.Heavy++
+		}
+
+		bucket := int(r.Result.Score / 10)
+		if bucket > 9 {
+			bucket = 9
+		}
+		sum.Histogram[bucket]++
+
+		for _, h := range r.Result.Hits {
+			match := h.Match
+			if h.Family != "" {
+				match = h.Family
+			}
+			key := strings.ToLower(match)
+			sh, ok := hitMap[key]
+			if !ok {
+				sh = &siteHit{
+					Match:    match,
+					Type:     h.Type,
+					Severity: h.Severity,
+					Detail:   h.Detail,
+				}
+				hitMap[key] = sh
+			}
+			sh.Count++
+			if !containsString(sh.Pages, r.URL) {
+				sh.Pages = append(sh.Pages, r.URL)
+			}
+		}
+	}
+
+	if len(results) > 0 {
+		sum.AverageScore = totalScore / float64(len(results))
+	}
+
+	for _, sh := range hitMap {
+		sum.TopHits = append(sum.TopHits, *sh)
+	}
+	sort.Slice(sum.TopHits, func(i, j int) bool {
+		if sum.TopHits[i].Count != sum.TopHits[j].Count {
+			return sum.TopHits[i].Count > sum.TopHits[j].Count
+		}
+		return strings.ToLower(sum.TopHits[i].Match) < strings.ToLower(sum.TopHits[j].Match)
+	})
+
+	return sum
+}
+
+// writeReport renders rep in the requested format to stdout or --output
+func writeReport(w io.Writer, format string, rep *siteReport) error {
+	var err error
+	switch format {
+	case "text":
+		printCrawlReport(w, rep)
+	case "json":
+		data, _ := json.MarshalIndent(newReportDocument(rep), "", "  ")
+		_, err = fmt.Fprintln(w, string(data))
+	case "html":
+		err = writeHTMLReport(w, rep)
+	}
+	if err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+	return nil
+}
+
+// createReportOutput opens --output for writing, or returns stdout
+func createReportOutput() (io.Writer, func() error, error) {
+	if reportOutput == "" || reportOutput == "-" {
+		return os.Stdout, func() error { return nil }, nil
+	}
+	f, err := os.Create(reportOutput)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating report file: %w", err)
+	}
+	colorOn = false
+	return f, f.Close, nil
+}
+
+// resultSink receives each page as soon as it is scored, for the row
+// formats that stream
+type resultSink func(crawlResult) error
+
+// rowSink writes each page as a scoreColumns row
+func rowSink(rows *rowWriter) resultSink {
+	return func(r crawlResult) error {
+		entry := newScoreEntry(r.URL, r.Result)
+		if err := rows.write(entry, entry.row()); err != nil {
+			return fmt.Errorf("writing report: %w", err)
+		}
+		return nil
+	}
+}
+
+// reportSchemaVersion is bumped whenever a field of reportDocument changes
+// meaning or is removed; new fields do not bump it
+const reportSchemaVersion = 1
+
+// reportDocument is the --json form of a report
+type reportDocument struct {
+	Schema        string         `json:"schema"`
+	SchemaVersion int            `json:"schema_version"`
+	Tool          reportTool     `json:"tool"`
+	Source        string         `json:"source"`
+	Mode          string         `json:"mode"`
+	Presets       []string       `json:"presets"`
+	RuleSetHash   string         `json:"rule_set_hash"`
+	StartedAt     time.Time      `json:"started_at"`
+	FinishedAt    time.Time      `json:"finished_at"`
+	Crawl         *crawlSettings `json:"crawl,omitempty"`
+	Aggregates    reportTotals   `json:"aggregates"`
+	Skipped       []skippedPage  `json:"skipped"`
+	NotCrawled    []skippedPage  `json:"not_crawled,omitempty"`
+	Pages         []crawlResult  `json:"pages"`
+}
+
+type reportTool struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// reportTotals is reportSummary plus the page and word counts
+type reportTotals struct {
+	PagesScanned int `json:"pages_scanned"`
+	PagesScored  int `json:"pages_scored"`
+	PagesSkipped int `json:"pages_skipped"`
+	TotalWords   int `json:"total_words"`
+	TotalHits    int `json:"total_hits"`
+	reportSummary
+}
+
+func newReportDocument(rep *siteReport) reportDocument {
+	doc := reportDocument{
+		Schema:        "slopsquid.report",
+		SchemaVersion: reportSchemaVersion,
+		Tool:          reportTool{Name: "slopsquid", Version: version},
+		Source:        rep.Source,
+		Mode:          rep.Mode,
+		Presets:       presets,
+		RuleSetHash:   rep.RuleSetHash,
+		StartedAt:     rep.StartedAt,
+		FinishedAt:    rep.FinishedAt,
+		Crawl:         rep.Crawl,
+		Aggregates: reportTotals{
+			PagesScanned:  rep.TotalPages,
+			PagesScored:   len(rep.Results),
+			PagesSkipped:  len(rep.SkippedPages),
+			TotalWords:    rep.TotalWords,
+			TotalHits:     rep.TotalHits,
+			reportSummary: summarize(rep.Results),
+		},
+		Skipped:    rep.SkippedPages,
+		NotCrawled: rep.NotCrawled,
+		Pages:      rep.Results,
+	}
+	// Stable shape for consumers: empty lists rather than null
+	if doc.Presets == nil {
+		doc.Presets = []string{}
+	}
+	if doc.Skipped == nil {
+		doc.Skipped = []skippedPage{}
+	}
+	if doc.Pages == nil {
+		doc.Pages = []crawlResult{}
+	}
+	if doc.Aggregates.TopHits == nil {
+		doc.Aggregates.TopHits = []siteHit{}
+	}
+	return doc
+}
+
+//go:embed report.html
+var reportTemplate string
+
+type htmlPage struct {
+	ID      int
+	URL     string
+	Display string
+	Score   float64
+	Rating  string
+	Words   int
+	Density float64
+	Hits    []htmlHit
+}
+
+type htmlHit struct {
+	Line     int
+	Severity string
+	Type     string
+	Rule     string
+	Detail   string
+	Before   string
+	Match    string
+	After    string
+}
+
+type htmlBucket struct {
+	Label   string
+	Count   int
+	Percent float64 // bar height relative to the tallest bucket
+}
+
+// writeHTMLReport renders a single self-contained HTML file: inline CSS and
+// JS, no external assets, so it can be mailed or opened offline
+func writeHTMLReport(w io.Writer, rep *siteReport) error {
+	tmpl, err := template.New("report").Parse(reportTemplate)
+	if err != nil {
+		return err
+	}
+
+	sum := summarize(rep.Results)
+
+	maxBucket := 0
+	for _, n := range sum.Histogram {
+		if n > maxBucket {
+			maxBucket = n
+		}
+	}
+	buckets := make([]htmlBucket, len(sum.Histogram))
+	for i, n := range sum.Histogram {
+		b := htmlBucket{Label: fmt.Sprintf("%d–%d", i*10, i*10+9), Count: n}
+		if i == 9 {
+			b.Label = "90–100"
+		}
+		if maxBucket > 0 {
+			b.Percent = float64(n) / float64(maxBucket) * 100
+		}
+		buckets[i] = b
+	}
+
+	pages := make([]htmlPage, len(rep.Results))
+	for i, r := range rep.Results {
+		p := htmlPage{
+			ID:      i,
+			URL:     r.URL,
+			Display: rep.displayURL(r.URL),
+			Score:   r.Result.Score,
+			Rating:  r.Result.Rating,
+			Words:   r.Result.WordCount,
+			Density: r.Result.Density,
+		}
+		for _, h := range r.Result.Hits {
+			hh := htmlHit{
+				Line:     h.Line,
+				Severity: h.Severity,
+				Type:     h.Type,
+				Rule:     h.Rule,
+				Detail:   h.Detail,
+				Match:    h.Match,
+			}
+			if h.Snippet != nil {
+				hh.Before, hh.Match, hh.After = h.Snippet.Before, h.Snippet.Match, h.Snippet.After
+			}
+			p.Hits = append(p.Hits, hh)
+		}
+		pages[i] = p
+	}
+
+	topHits := sum.TopHits
+	if len(topHits) > 25 {
+		topHits = topHits[:25]
+	}
+
+	return tmpl.Execute(w, struct {
+		*siteReport
+		Summary   reportSummary
+		TopHits   []siteHit
+		Histogram []htmlBucket
+		Pages     []htmlPage
+		Generated string
+		Version   string
+	}{
+		siteReport: rep,
+		Summary:    sum,
+		TopHits:    topHits,
+		Histogram:  buckets,
+		Pages:      pages,
+		Generated:  time.Now().Format("2006-01-02 15:04 MST"),
+		Version:    version,
+	})
+}
+
+// displayURL shortens a page URL for display: the path alone on the host
+// the crawl started from, host and path on any other
+func (rep *siteReport) displayURL(u string) string {
+	if rep.Mode == "crawl" && !sameHost(u, rep.Source) {
+		if idx := strings.Index(u, "://"); idx != -1 {
+			return u[idx+3:]
+		}
+	}
+	return shortPath(u)
+}
+
+func sameHost(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	return errA == nil && errB == nil && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// shortPath drops the scheme and host from a URL for display
+func shortPath(u string) string {
+	if idx := strings.Index(u, "://"); idx != -1 {
+		after := u[idx+3:]
+		if slashIdx := strings.Index(after, "/"); slashIdx != -1 {
+			return after[slashIdx:]
+		}
+	}
+	return u
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QRY91/slopsquid/internal/detector"
+)
+
+// testReport scores a few pages of a crawled site
+func testReport(t *testing.T) *siteReport {
+	t.Helper()
+	d, err := detector.NewDetector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := map[string]string{
+		"https://example.com/":          "A plain page about our opening hours and the address of the shop.",
+		"https://example.com/blog/post": "We delve into the tapestry. Her gaze flickered. <script>alert(1)</script> We delve again.",
+		"https://docs.example.com/a":    "The lamp flickered while the team delved into the logs and the tapestry.",
+	}
+	rep := &siteReport{
+		Source:      "https://example.com/",
+		Mode:        "crawl",
+		RuleSetHash: d.RuleSetHash(),
+		StartedAt:   time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+		FinishedAt:  time.Date(2026, 10, 1, 12, 1, 0, 0, time.UTC),
+		Crawl:       &crawlSettings{MaxDepth: 3, MaxPages: 100},
+		SkippedPages: []skippedPage{
+			{URL: "https://example.com/empty", Reason: "fewer than 10 words"},
+		},
+		NotCrawled: []skippedPage{{URL: "https://other.com/", Reason: "host other.com is out of scope"}},
+	}
+	for _, u := range []string{"https://example.com/", "https://example.com/blog/post", "https://docs.example.com/a"} {
+		res := d.Scan(pages[u])
+		rep.Results = append(rep.Results, crawlResult{URL: u, Result: res})
+		rep.TotalWords += res.WordCount
+		rep.TotalHits += len(res.Hits)
+	}
+	rep.TotalPages = len(rep.Results) + len(rep.SkippedPages)
+	return rep
+}
+
+func TestSummarize(t *testing.T) {
+	rep := testReport(t)
+	sum := summarize(rep.Results)
+	if sum.Clean+sum.Moderate+sum.Heavy != 3 {
+		t.Errorf("ratings %d/%d/%d don't add up to 3 pages", sum.Clean, sum.Moderate, sum.Heavy)
+	}
+	pages := 0
+	for _, n := range sum.Histogram {
+		pages += n
+	}
+	if pages != 3 {
+		t.Errorf("histogram holds %d pages, want 3", pages)
+	}
+
+	// Stem families are tallied under the entry, across pages
+	counts := make(map[string]siteHit)
+	for _, h := range sum.TopHits {
+		counts[h.Match] = h
+	}
+	if h := counts["delve"]; h.Count != 3 || len(h.Pages) != 2 {
+		t.Errorf("delve tallied %d times on %d pages, want 3 on 2", h.Count, len(h.Pages))
+	}
+	if h := counts["flicker"]; h.Count != 2 {
+		t.Errorf("flicker tallied %d times, want 2", h.Count)
+	}
+	if sum.TopHits[0].Match != "delve" {
+		t.Errorf("top hit = %s, want the most frequent, delve", sum.TopHits[0].Match)
+	}
+}
+
+func TestReportDocument(t *testing.T) {
+	rep := testReport(t)
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "json", rep); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"schema", "schema_version", "tool", "source", "mode", "presets",
+		"rule_set_hash", "started_at", "finished_at", "crawl", "aggregates", "skipped", "not_crawled", "pages"} {
+		if _, ok := doc[key]; !ok {
+			t.Errorf("document has no %q", key)
+		}
+	}
+	if doc["schema"] != "slopsquid.report" || doc["schema_version"] != float64(1) {
+		t.Errorf("schema = %v v%v", doc["schema"], doc["schema_version"])
+	}
+	if doc["started_at"] != "2026-10-01T12:00:00Z" {
+		t.Errorf("started_at = %v", doc["started_at"])
+	}
+	agg := doc["aggregates"].(map[string]any)
+	for key, want := range map[string]float64{"pages_scanned": 4, "pages_scored": 3, "pages_skipped": 1} {
+		if agg[key] != want {
+			t.Errorf("aggregates.%s = %v, want %v", key, agg[key], want)
+		}
+	}
+	for _, key := range []string{"clean", "moderate", "heavy", "average_score", "histogram", "top_hits", "total_words", "total_hits"} {
+		if _, ok := agg[key]; !ok {
+			t.Errorf("aggregates has no %q", key)
+		}
+	}
+	if pages := doc["pages"].([]any); len(pages) != 3 {
+		t.Errorf("%d pages, want 3", len(pages))
+	}
+
+	// Empty lists stay lists, and local reports have no crawl block
+	local := &siteReport{Source: "/docs", Mode: "local"}
+	buf.Reset()
+	if err := writeReport(&buf, "json", local); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{`"presets": []`, `"skipped": []`, `"pages": []`, `"top_hits": []`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("empty report lacks %s", want)
+		}
+	}
+	if strings.Contains(out, `"crawl"`) || strings.Contains(out, "null") {
+		t.Errorf("empty local report has a crawl block or nulls:\n%s", out)
+	}
+}
+
+func TestHTMLReport(t *testing.T) {
+	rep := testReport(t)
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "html", rep); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+
+	// Self-contained: nothing is loaded from elsewhere
+	if m := regexp.MustCompile(`(?i)<(?:script|link|img)[^>]+(?:src|href)=`).FindString(out); m != "" {
+		t.Errorf("report loads an external asset: %s", m)
+	}
+	// Page text is escaped, never run
+	if strings.Contains(out, "<script>alert(1)</script>") {
+		t.Error("page text was not escaped")
+	}
+	for _, want := range []string{
+		"/blog/post",          // pages on the root host by path
+		"docs.example.com/a",  // others with their host
+		"90–100",              // histogram
+		"fewer than 10 words", // skipped pages
+		"tapestry",            // top hits
+		"<mark",               // highlighted excerpts
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("report lacks %q", want)
+		}
+	}
+}
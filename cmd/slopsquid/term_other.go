@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import "os"
+
+// terminalWidth is unknown on this platform; COLUMNS still applies
+func terminalWidth(f *os.File) int {
+	return 0
+}
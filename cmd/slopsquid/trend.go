@@ -0,0 +1,316 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/QRY91/slopsquid/internal/history"
+	"github.com/spf13/cobra"
+)
+
+var (
+	trendThreshold float64
+	trendRuns      int
+)
+
+var trendCmd = &cobra.Command{
+	Use:   "trend [url|directory]",
+	Short: "Show how report scores changed across recorded runs",
+	Long: `Trend reads the history that every report run records, and shows how the
+average score moved over time. It also lists the pages that regressed or
+improved since the previous run.
+
+  slopsquid trend                  list every recorded site and its latest score
+  slopsquid trend qry.zone         run history and page changes for one site
+  slopsquid trend ./docs --format csv > trend.csv
+
+A page regresses when its score rises by --threshold or more, or its rating
+gets worse.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		source := ""
+		if len(args) == 1 {
+			source = normalizeSource(args[0])
+		}
+		return runTrend(source)
+	},
+}
+
+func init() {
+	trendCmd.Flags().StringVar(&stateDir, "state-dir", "", "history directory (default $XDG_STATE_HOME/slopsquid)")
+	trendCmd.Flags().Float64Var(&trendThreshold, "threshold", 5, "score change that counts as a regression or improvement")
+	trendCmd.Flags().IntVar(&trendRuns, "runs", 10, "number of most recent runs to show")
+	trendCmd.Flags().StringVar(&outputFormat, "format", "text", "output format: text, json or csv (one row per page per run)")
+}
+
+// normalizeSource turns a report target into the key its runs are stored under
+func normalizeSource(target string) string {
+	if isURL(target) {
+		if !strings.HasPrefix(target, "http") {
+			target = "https://" + target
+		}
+		return target
+	}
+	abs, err := filepath.Abs(target)
+	if err != nil {
+		return target
+	}
+	return abs
+}
+
+func openHistory() (*history.Store, error) {
+	dir, err := resolveStateDir()
+	if err != nil {
+		return nil, err
+	}
+	return history.Open(dir)
+}
+
+// resolveStateDir returns --state-dir or the default state directory, which
+// holds run history and crawl state
+func resolveStateDir() (string, error) {
+	if stateDir != "" {
+		return stateDir, nil
+	}
+	return history.DefaultDir()
+}
+
+// recordRun appends a finished report to the history store
+func recordRun(rep *siteReport) error {
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+
+	sum := summarize(rep.Results)
+	run := history.Run{
+		RunAt:        rep.FinishedAt,
+		Source:       rep.Source,
+		Mode:         rep.Mode,
+		Version:      version,
+		RuleSetHash:  rep.RuleSetHash,
+		AverageScore: sum.AverageScore,
+		TotalWords:   rep.TotalWords,
+		TotalHits:    rep.TotalHits,
+		Clean:        sum.Clean,
+		Moderate:     sum.Moderate,
+		Heavy:        sum.Heavy,
+		Partial:      rep.Crawl != nil && (rep.Crawl.Partial || rep.Crawl.Unchanged > 0),
+	}
+	for _, r := range rep.Results {
+		run.Pages = append(run.Pages, history.PageScore{
+			Path:   r.URL,
+			Score:  r.Result.Score,
+			Rating: r.Result.Rating,
+			Hits:   len(r.Result.Hits),
+			Words:  r.Result.WordCount,
+		})
+	}
+	return store.Append(run)
+}
+
+func runTrend(source string) error {
+	format, err := resolveFormat("text", "json", "csv")
+	if err != nil {
+		return err
+	}
+
+	store, err := openHistory()
+	if err != nil {
+		return err
+	}
+	runs, err := store.Runs(source)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "json":
+		if runs == nil {
+			runs = []history.Run{}
+		}
+		data, _ := json.MarshalIndent(runs, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	case "csv":
+		return writeTrendCSV(runs)
+	}
+
+	if len(runs) == 0 {
+		if source == "" {
+			fmt.Printf("no report runs recorded yet in %s\n", store.Path())
+		} else {
+			fmt.Printf("no report runs recorded for %s\n", source)
+		}
+		return nil
+	}
+
+	if source == "" {
+		printTrendSources(runs)
+		return nil
+	}
+	printTrend(source, runs)
+	return nil
+}
+
+var trendColumns = []string{
+	"run_at", "source", "rule_set_hash", "path", "score", "rating", "hits", "words",
+}
+
+// writeTrendCSV exports every page of every run, one row each
+func writeTrendCSV(runs []history.Run) error {
+	rows, err := newRowWriter(os.Stdout, "csv", trendColumns)
+	if err != nil {
+		return err
+	}
+	for _, run := range runs {
+		for _, p := range run.Pages {
+			row := []string{
+				run.RunAt.Format("2006-01-02T15:04:05Z07:00"),
+				run.Source,
+				run.RuleSetHash,
+				p.Path,
+				formatFloat(p.Score),
+				p.Rating,
+				strconv.Itoa(p.Hits),
+				strconv.Itoa(p.Words),
+			}
+			if err := rows.write(nil, row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// printTrendSources summarizes every recorded site: its latest average and
+// the change from the run before
+func printTrendSources(runs []history.Run) {
+	latest := make(map[string][]history.Run)
+	var order []string
+	for _, r := range runs {
+		if _, ok := latest[r.Source]; !ok {
+			order = append(order, r.Source)
+		}
+		latest[r.Source] = append(latest[r.Source], r)
+	}
+
+	fmt.Printf("\n== SlopSquid Trends ==\n\n")
+	for _, src := range order {
+		rs := latest[src]
+		last := rs[len(rs)-1]
+		delta := ""
+		if len(rs) > 1 {
+			delta = formatDelta(last.AverageScore - rs[len(rs)-2].AverageScore)
+		}
+		fmt.Printf("  %5.1f %-7s  %3d runs  last %s  %s\n",
+			last.AverageScore, delta, len(rs), last.RunAt.Local().Format("2006-01-02 15:04"), src)
+	}
+	fmt.Println()
+}
+
+// printTrend shows one site's run history and the page changes since the
+// previous run
+func printTrend(source string, runs []history.Run) {
+	fmt.Printf("\n== SlopSquid Trend ==\n")
+	fmt.Printf("   Source: %s\n", source)
+	fmt.Printf("   Runs: %d (%s → %s)\n\n", len(runs),
+		runs[0].RunAt.Local().Format("2006-01-02"), runs[len(runs)-1].RunAt.Local().Format("2006-01-02"))
+
+	shown := runs
+	if trendRuns > 0 && len(shown) > trendRuns {
+		shown = shown[len(shown)-trendRuns:]
+	}
+	offset := len(runs) - len(shown)
+
+	fmt.Printf("-- Runs --\n")
+	fmt.Printf("  %-16s  %5s  %-7s  %5s  %5s  %s\n", "date", "avg", "change", "pages", "hits", "clean/moderate/heavy")
+	for i, r := range shown {
+		delta := ""
+		rulesChanged := ""
+		if idx := offset + i; idx > 0 {
+			prev := runs[idx-1]
+			delta = formatDelta(r.AverageScore - prev.AverageScore)
+			if prev.RuleSetHash != r.RuleSetHash {
+				rulesChanged = "  (rules changed)"
+			}
+		}
+		if r.Partial {
+			rulesChanged += "  (partial)"
+		}
+		fmt.Printf("  %-16s  %5.1f  %-7s  %5d  %5d  %d/%d/%d%s\n",
+			r.RunAt.Local().Format("2006-01-02 15:04"), r.AverageScore, delta,
+			len(r.Pages), r.TotalHits, r.Clean, r.Moderate, r.Heavy, rulesChanged)
+	}
+
+	// Partial runs would show every page they skipped as no longer scored,
+	// so page changes are between the last two complete runs
+	var complete []history.Run
+	for _, r := range runs {
+		if !r.Partial {
+			complete = append(complete, r)
+		}
+	}
+	if len(complete) < 2 {
+		fmt.Printf("\n  (page changes appear after two complete reports; interrupted and --since runs don't count)\n\n")
+		return
+	}
+	if runs[len(runs)-1].Partial {
+		fmt.Printf("\n  (the latest run was partial; page changes are from the last complete run)\n")
+	}
+
+	prev, last := complete[len(complete)-2], complete[len(complete)-1]
+	changes := history.ComparePages(prev.Pages, last.Pages, trendThreshold)
+
+	sections := []struct {
+		status string
+		title  string
+	}{
+		{history.Regressed, "Regressed"},
+		{history.Added, "New pages"},
+		{history.Improved, "Improved"},
+		{history.Removed, "No longer scored"},
+	}
+	for _, sec := range sections {
+		var lines []history.PageChange
+		for _, c := range changes {
+			if c.Status == sec.status {
+				lines = append(lines, c)
+			}
+		}
+		if len(lines) == 0 {
+			continue
+		}
+		fmt.Printf("\n-- %s since %s (%d) --\n", sec.title, prev.RunAt.Local().Format("2006-01-02 15:04"), len(lines))
+		for _, c := range lines {
+			fmt.Printf("  %s\n", formatPageChange(c))
+		}
+	}
+	fmt.Println()
+}
+
+func formatPageChange(c history.PageChange) string {
+	path := shortPath(c.Path)
+	switch c.Status {
+	case history.Added:
+		return fmt.Sprintf("%s       → %5.1f          %s", ratingIcon(c.AfterRating), c.After, path)
+	case history.Removed:
+		return fmt.Sprintf("%s %5.1f →                %s", ratingIcon(c.BeforeRating), c.Before, path)
+	}
+	return fmt.Sprintf("%s %5.1f → %5.1f (%s)  %s", ratingIcon(c.AfterRating), c.Before, c.After, formatDelta(c.Delta), path)
+}
+
+func formatDelta(d float64) string {
+	s := fmt.Sprintf("%+.1f", d)
+	switch {
+	case d >= 0.05:
+		return paint("high", s)
+	case d <= -0.05:
+		return paint("clean", s)
+	}
+	return s
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/QRY91/slopsquid/internal/cache"
+	"github.com/QRY91/slopsquid/internal/detector"
+	"github.com/QRY91/slopsquid/internal/scanner"
+	"github.com/QRY91/slopsquid/internal/watch"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchDebounce time.Duration
+	watchInterval time.Duration
+	watchPoll     bool
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <directory>",
+	Short: "Rescan files as they are saved and show score changes",
+	Long: `Watch scores every file under a directory, then rescans each file when it
+is saved, printing the score change and any hits that are new since the last
+save. Only files that scan would pick up are watched.
+
+File events come from inotify on Linux; other platforms (or --poll) compare
+modification times every --interval. Rapid saves are batched until changes
+go quiet for --debounce.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runWatch(args[0])
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchDebounce, "debounce", 300*time.Millisecond, "wait this long after the last change before rescanning")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", time.Second, "polling interval when native file events are unavailable")
+	watchCmd.Flags().BoolVar(&watchPoll, "poll", false, "poll for changes even where native file events are available")
+}
+
+// watchSession holds the last result for every watched file
+type watchSession struct {
+	d       *detector.Detector
+	cache   *cache.Cache
+	scanner *scanner.Scanner
+	filter  *scanner.PathFilter // the scan's rules, for files that change
+	root    string
+	results map[string]*detector.ScanResult
+}
+
+func runWatch(target string) error {
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", target)
+	}
+	root, _ := filepath.Abs(target)
+
+	d, files, err := getDetectorAndFiles([]string{root})
+	if err != nil {
+		return err
+	}
+
+	s := &watchSession{
+		d:       d,
+		cache:   openScanCache(d),
+		scanner: scanner.NewScanner(scanOptions()),
+		root:    root,
+		results: make(map[string]*detector.ScanResult),
+	}
+	for _, f := range files {
+		if f.Error != "" || len(f.Content) < 20 {
+			continue
+		}
+		s.results[f.Path] = scanCached(d, s.cache, f.Content)
+	}
+
+	filter, err := s.scanner.NewFilter(root)
+	if err != nil {
+		return err
+	}
+	s.filter = filter
+	w, err := watch.New(root, filter, watch.Options{
+		Debounce: watchDebounce,
+		Interval: watchInterval,
+		Poll:     watchPoll,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("watching %s (%s) — %d files, average score %.1f\n",
+		root, w.Backend(), len(s.results), s.average())
+	fmt.Println(paint("dim", "press Ctrl-C to stop"))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return w.Run(ctx, s.rescan)
+}
+
+func (s *watchSession) average() float64 {
+	if len(s.results) == 0 {
+		return 0
+	}
+	var total float64
+	for _, r := range s.results {
+		total += r.Score
+	}
+	return total / float64(len(s.results))
+}
+
+// rescan scores each changed file and prints what moved
+func (s *watchSession) rescan(paths []string) {
+	before := s.average()
+	fmt.Printf("\n%s\n", paint("dim", time.Now().Format("15:04:05")))
+
+	for _, path := range paths {
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			rel = path
+		}
+		prev := s.results[path]
+
+		file, err := s.filter.ScanFile(path)
+		if err != nil || file == nil || file.Error != "" || len(file.Content) < 20 {
+			if prev != nil {
+				delete(s.results, path)
+				fmt.Printf("  %s %s\n", paint("dim", "removed"), rel)
+			}
+			if file != nil && file.Error != "" && verbose {
+				fmt.Fprintf(os.Stderr, "skip %s: %s\n", path, file.Error)
+			}
+			continue
+		}
+
+		result := scanCached(s.d, s.cache, file.Content)
+		result.Path = path
+		s.results[path] = result
+
+		icon := ratingIcon(result.Rating)
+		switch {
+		case prev == nil:
+			fmt.Printf("%s %5.1f %-7s %s %s\n", icon, result.Score, "", rel, paint("dim", "(new)"))
+		case prev.Score != result.Score:
+			fmt.Printf("%s %5.1f %-7s %s\n", icon, result.Score, formatDelta(result.Score-prev.Score), rel)
+		default:
+			fmt.Printf("%s %5.1f %-7s %s\n", icon, result.Score, "", rel)
+		}
+
+		for _, h := range newHits(prev, result) {
+			fmt.Println(wrap(fmt.Sprintf("    %s line %d: %q — %s", severityTag(h.Severity), h.Line, h.Match, h.Detail), 9))
+			if h.Snippet != nil {
+				fmt.Println(wrap("         "+formatSnippet(h.Snippet, h.Severity), 9))
+			}
+		}
+	}
+
+	if after := s.average(); after != before {
+		fmt.Printf("  average %.1f → %.1f (%s)\n", before, after, formatDelta(after-before))
+	}
+}
+
+// newHits returns the hits in result that prev did not have. Hits are
+// matched on rule and text rather than position, since an edit above a hit
+// moves it without making it new.
+func newHits(prev, result *detector.ScanResult) []detector.Hit {
+	if prev == nil {
+		return result.Hits
+	}
+	key := func(h detector.Hit) string {
+		return h.Type + "\x00" + h.Rule + "\x00" + strings.ToLower(h.Match)
+	}
+	seen := make(map[string]int)
+	for _, h := range prev.Hits {
+		seen[key(h)]++
+	}
+	var added []detector.Hit
+	for _, h := range result.Hits {
+		if seen[key(h)] > 0 {
+			seen[key(h)]--
+			continue
+		}
+		added = append(added, h)
+	}
+	return added
+}
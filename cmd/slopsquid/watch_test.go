@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/QRY91/slopsquid/internal/detector"
+)
+
+func TestNewHits(t *testing.T) {
+	hit := func(rule, match string, line int) detector.Hit {
+		return detector.Hit{Type: "word", Rule: rule, Match: match, Line: line}
+	}
+	prev := &detector.ScanResult{Hits: []detector.Hit{
+		hit("delve", "delve", 3),
+		hit("tapestry", "tapestry", 8),
+	}}
+	result := &detector.ScanResult{Hits: []detector.Hit{
+		hit("delve", "Delve", 5),        // moved and recased by an edit above it
+		hit("tapestry", "tapestry", 10), // moved
+		hit("tapestry", "tapestry", 12), // a second use is new
+		hit("testament", "testament", 1),
+	}}
+
+	got := newHits(prev, result)
+	if len(got) != 2 || got[0].Line != 12 || got[1].Rule != "testament" {
+		t.Errorf("newHits = %+v, want the second tapestry and testament", got)
+	}
+	if got := newHits(nil, result); len(got) != len(result.Hits) {
+		t.Errorf("newHits with no previous scan = %d hits, want all %d", len(got), len(result.Hits))
+	}
+	if got := newHits(result, prev); len(got) != 0 {
+		t.Errorf("newHits after removing hits = %+v, want none", got)
+	}
+}
@@ -0,0 +1,165 @@
+// Package archive serves saved web captures (WARC files, HAR files and wget
+// mirrors) as an http.RoundTripper, so the crawler can run over them
+// offline exactly as it would over the network.
+package archive
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxBody caps how much of one archived response is kept in memory; the
+// crawler reads no more than this of a page anyway
+const maxBody = 10 * 1024 * 1024
+
+// Archive answers requests from a capture. URLs the capture doesn't hold get
+// a 404, so a missing robots.txt or page behaves as it would live.
+type Archive struct {
+	kind    string // "warc", "har" or "mirror"
+	entries map[string]*entry
+	root    string // the first HTML page captured
+
+	// mirror looks responses up in a directory instead of entries
+	mirror *mirror
+}
+
+// entry is one archived response
+type entry struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// Open reads the capture at path: a .warc or .warc.gz file, or a .har file
+func Open(path string) (*Archive, error) {
+	if info, err := os.Stat(path); err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+	if strings.HasSuffix(strings.ToLower(path), ".har") {
+		return openHAR(path)
+	}
+	if IsArchive(path) {
+		return openWARC(path)
+	}
+	return nil, fmt.Errorf("%s: not a WARC or HAR file", path)
+}
+
+// IsArchive reports whether path is named like a capture Open reads
+func IsArchive(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasSuffix(lower, ".har") || strings.HasSuffix(lower, ".warc") || strings.HasSuffix(lower, ".warc.gz")
+}
+
+func newArchive(kind string) *Archive {
+	return &Archive{kind: kind, entries: make(map[string]*entry)}
+}
+
+// Kind returns "warc", "har" or "mirror"
+func (a *Archive) Kind() string {
+	return a.kind
+}
+
+// Len returns how many responses the capture holds; zero for mirrors, which
+// are read on demand
+func (a *Archive) Len() int {
+	return len(a.entries)
+}
+
+// Root returns the URL of the first HTML page in the capture, where a crawl
+// of it should start
+func (a *Archive) Root() string {
+	return a.root
+}
+
+// add stores a response, keeping the first capture of each URL
+func (a *Archive) add(rawURL string, e *entry) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return
+	}
+	k := key(u)
+	if _, ok := a.entries[k]; ok {
+		return
+	}
+	a.entries[k] = e
+	if a.root == "" && e.status == http.StatusOK && strings.Contains(e.header.Get("Content-Type"), "html") {
+		a.root = u.String()
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (a *Archive) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	var e *entry
+	if a.mirror != nil {
+		e = a.mirror.lookup(req.URL)
+	} else {
+		e = a.entries[key(req.URL)]
+	}
+	if e == nil {
+		e = &entry{
+			status: http.StatusNotFound,
+			header: http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+			body:   []byte("not in archive\n"),
+		}
+	}
+
+	header := e.header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", e.status, http.StatusText(e.status)),
+		StatusCode:    e.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}, nil
+}
+
+// key identifies a URL regardless of host case, default port, fragment and
+// query parameter order
+func key(u *url.URL) string {
+	k := *u
+	k.Scheme = strings.ToLower(k.Scheme)
+	k.Host = strings.ToLower(k.Host)
+	if host, port, err := net.SplitHostPort(k.Host); err == nil {
+		if (k.Scheme == "http" && port == "80") || (k.Scheme == "https" && port == "443") {
+			k.Host = host
+		}
+	}
+	if k.Path == "" {
+		k.Path = "/"
+	}
+	k.RawPath = ""
+	k.Fragment = ""
+	k.RawFragment = ""
+	if k.RawQuery != "" {
+		k.RawQuery = k.Query().Encode()
+	}
+	k.ForceQuery = false
+	return k.String()
+}
+
+// keepBody reports whether a response's body is worth holding in memory:
+// text the crawler might read, but not images or media
+func keepBody(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	return ct == "" || strings.HasPrefix(ct, "text/") || strings.Contains(ct, "xml") ||
+		strings.Contains(ct, "json") || strings.Contains(ct, "gzip")
+}
@@ -0,0 +1,239 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// get fetches rawURL from a and returns the status and body
+func get(t *testing.T, a *Archive, rawURL string) (int, string) {
+	t.Helper()
+	resp, err := (&http.Client{Transport: a}).Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+// warcRecord builds one WARC record holding an HTTP message
+func warcRecord(kind, uri, message string) string {
+	return fmt.Sprintf("WARC/1.0\r\nWARC-Type: %s\r\nWARC-Target-URI: <%s>\r\n"+
+		"Content-Type: application/http; msgtype=%s\r\nContent-Length: %d\r\n\r\n%s\r\n\r\n",
+		kind, uri, kind, len(message), message)
+}
+
+func gzipped(t *testing.T, s string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func testWARC(t *testing.T) []string {
+	page := "<html><body><p>Home</p></body></html>"
+	about := gzipped(t, "<html><body><p>About</p></body></html>")
+	return []string{
+		warcRecord("request", "https://example.com/", "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"),
+		warcRecord("response", "https://example.com/style.css",
+			"HTTP/1.1 200 OK\r\nContent-Type: text/css\r\nContent-Length: 4\r\n\r\nbody"),
+		warcRecord("response", "https://example.com/",
+			fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Length: %d\r\n\r\n%s", len(page), page)),
+		warcRecord("response", "https://example.com/about?b=2&a=1",
+			fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Type: text/html\r\nContent-Encoding: gzip\r\nContent-Length: %d\r\n\r\n%s", len(about), about)),
+		warcRecord("response", "https://example.com/",
+			"HTTP/1.1 500 Internal Server Error\r\nContent-Length: 0\r\n\r\n"),
+	}
+}
+
+func checkWARC(t *testing.T, a *Archive) {
+	t.Helper()
+	if a.Kind() != "warc" || a.Len() != 3 {
+		t.Errorf("kind %s with %d responses, want warc with 3", a.Kind(), a.Len())
+	}
+	if a.Root() != "https://example.com/" {
+		t.Errorf("root = %q, want the first HTML page", a.Root())
+	}
+	if status, body := get(t, a, "https://EXAMPLE.com:443/#top"); status != 200 || !strings.Contains(body, "Home") {
+		t.Errorf("root: %d %q, want the first capture", status, body)
+	}
+	if status, body := get(t, a, "https://example.com/about?a=1&b=2"); status != 200 || !strings.Contains(body, "About") {
+		t.Errorf("about: %d %q, want the decompressed page", status, body)
+	}
+	if status, _ := get(t, a, "https://example.com/robots.txt"); status != http.StatusNotFound {
+		t.Errorf("robots.txt: %d, want 404", status)
+	}
+}
+
+func TestOpenWARC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site.warc")
+	if err := os.WriteFile(path, []byte(strings.Join(testWARC(t), "")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWARC(t, a)
+}
+
+func TestOpenWARCGzip(t *testing.T) {
+	// One gzip member per record, as crawlers write them
+	var data strings.Builder
+	for _, record := range testWARC(t) {
+		data.WriteString(gzipped(t, record))
+	}
+	path := filepath.Join(t.TempDir(), "site.warc.gz")
+	if err := os.WriteFile(path, []byte(data.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkWARC(t, a)
+}
+
+func TestOpenHAR(t *testing.T) {
+	har := fmt.Sprintf(`{"log": {"entries": [
+		{"request": {"method": "GET", "url": "https://example.com/logo.png"},
+		 "response": {"status": 200, "content": {"mimeType": "image/png", "text": ""}}},
+		{"request": {"method": "POST", "url": "https://example.com/"},
+		 "response": {"status": 200, "content": {"mimeType": "text/html", "text": "posted"}}},
+		{"request": {"method": "GET", "url": "https://example.com/pending"},
+		 "response": {"status": 0, "content": {}}},
+		{"request": {"method": "GET", "url": "https://example.com/"},
+		 "response": {"status": 200,
+		  "headers": [{"name": ":status", "value": "200"}, {"name": "Content-Encoding", "value": "br"}],
+		  "content": {"mimeType": "text/html", "text": "<p>Home</p>"}}},
+		{"request": {"method": "GET", "url": "https://example.com/data"},
+		 "response": {"status": 200, "content": {"mimeType": "text/plain", "encoding": "base64", "text": %q}}}
+	]}}`, base64.StdEncoding.EncodeToString([]byte("decoded")))
+	path := filepath.Join(t.TempDir(), "site.har")
+	if err := os.WriteFile(path, []byte(har), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Kind() != "har" || a.Len() != 3 || a.Root() != "https://example.com/" {
+		t.Errorf("kind %s, %d responses, root %q", a.Kind(), a.Len(), a.Root())
+	}
+	resp, err := (&http.Client{Transport: a}).Get("https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get(":status") != "" {
+		t.Errorf("headers = %v, want no encoding or pseudo-headers", resp.Header)
+	}
+	tests := []struct {
+		url    string
+		status int
+		body   string
+	}{
+		{"https://example.com/", 200, "<p>Home</p>"},
+		{"https://example.com/data", 200, "decoded"},
+		{"https://example.com/pending", 404, "not in archive\n"},
+	}
+	for _, tt := range tests {
+		if status, body := get(t, a, tt.url); status != tt.status || body != tt.body {
+			t.Errorf("%s: %d %q, want %d %q", tt.url, status, body, tt.status, tt.body)
+		}
+	}
+}
+
+func TestMirrorLookup(t *testing.T) {
+	root := t.TempDir()
+	host := filepath.Join(root, "example.com")
+	files := map[string]string{
+		"index.html":                "home",
+		"docs/index.html":           "docs",
+		"docs/guide.html":           "guide",
+		"docs/setup":                "setup",
+		"docs/search?a=1&b=2":       "search",
+		"docs/list@page=2":          "list",
+		"docsfoo/index.html":        "docsfoo",
+		"docs/deep/nested/page.htm": "nested",
+	}
+	for name, text := range files {
+		path := filepath.Join(host, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		url  string
+		want string // "" for a 404
+	}{
+		{"http://example.com/docs/", "docs"},
+		{"http://example.com/docs", "docs"},
+		{"http://example.com/docs/guide", "guide"},
+		{"http://example.com/docs/guide.html", "guide"},
+		{"http://example.com/docs/setup", "setup"},
+		{"http://example.com/docs/search?b=2&a=1", "search"},
+		{"http://example.com/docs/list?page=2", "list"},
+		{"http://example.com/docs/deep/nested/page.htm", "nested"},
+		{"http://example.com/docsfoo/", ""}, // outside the base path
+		{"http://example.com/", ""},
+		{"http://other.com/docs/", ""},
+	}
+	// The host directory wget creates, and the base path's own directory
+	for _, dir := range []string{host, filepath.Join(host, "docs")} {
+		a, err := OpenMirror(dir, "http://example.com/docs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Root() != "http://example.com/docs/" {
+			t.Errorf("root = %q", a.Root())
+		}
+		for _, tt := range tests {
+			status, body := get(t, a, tt.url)
+			if tt.want == "" {
+				if status != http.StatusNotFound {
+					t.Errorf("%s: %s = %d %q, want 404", dir, tt.url, status, body)
+				}
+				continue
+			}
+			if status != 200 || body != tt.want {
+				t.Errorf("%s: %s = %d %q, want %q", dir, tt.url, status, body, tt.want)
+			}
+		}
+	}
+
+	// Without a URL the directory names the site
+	a, err := OpenMirror(host, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := get(t, a, "http://example.com/docsfoo/"); status != 200 || body != "docsfoo" {
+		t.Errorf("whole-site mirror: %d %q", status, body)
+	}
+	if _, err := OpenMirror(root, ""); err == nil {
+		t.Error("a directory not named after a host was accepted without a URL")
+	}
+}
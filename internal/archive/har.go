@@ -0,0 +1,86 @@
+package archive
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// harFile is the part of a HAR 1.2 file the archive reads
+type harFile struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				Method string `json:"method"`
+				URL    string `json:"url"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Headers []struct {
+					Name  string `json:"name"`
+					Value string `json:"value"`
+				} `json:"headers"`
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// openHAR reads the GET responses of a HAR file, as saved by a browser's
+// developer tools
+func openHAR(path string) (*Archive, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	a := newArchive("har")
+	for _, e := range har.Log.Entries {
+		if m := e.Request.Method; m != "" && m != http.MethodGet {
+			continue
+		}
+		// Status 0 marks a request the browser never got an answer to
+		resp := e.Response
+		if resp.Status == 0 {
+			continue
+		}
+
+		header := make(http.Header)
+		for _, h := range resp.Headers {
+			// HTTP/2 pseudo-headers such as ":status" aren't headers
+			if h.Name != "" && !strings.HasPrefix(h.Name, ":") {
+				header.Add(h.Name, h.Value)
+			}
+		}
+		// The saved text is already decoded and decompressed
+		header.Del("Content-Encoding")
+		header.Del("Content-Length")
+		header.Del("Transfer-Encoding")
+		if resp.Content.MimeType != "" {
+			header.Set("Content-Type", resp.Content.MimeType)
+		}
+
+		body := []byte(resp.Content.Text)
+		if resp.Content.Encoding == "base64" {
+			if body, err = base64.StdEncoding.DecodeString(resp.Content.Text); err != nil {
+				continue
+			}
+		}
+		if len(body) > maxBody {
+			body = body[:maxBody]
+		}
+		a.add(e.Request.URL, &entry{status: resp.Status, header: header, body: body})
+	}
+	return a, nil
+}
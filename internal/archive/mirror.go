@@ -0,0 +1,158 @@
+package archive
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// mirror maps URLs onto a directory saved by wget --mirror. wget saves
+// http://example.com/docs/a.html as example.com/docs/a.html, so dir may be
+// the host directory, holding docs/a.html, or the base path's own directory,
+// holding a.html. Only paths under the base are served.
+type mirror struct {
+	dir  string
+	base *url.URL
+	// hostDir is set when dir is laid out from the site root, which is taken
+	// to be the case when the base path exists as a directory inside it
+	hostDir bool
+}
+
+// OpenMirror serves the wget mirror in dir as the site at rawURL. With no
+// URL, dir must be named after the site's host, as wget names it.
+func OpenMirror(dir, rawURL string) (*Archive, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	if rawURL == "" {
+		host := filepath.Base(filepath.Clean(dir))
+		if !strings.Contains(host, ".") {
+			return nil, fmt.Errorf("can't tell which site %s mirrors; pass its URL with --mirror", dir)
+		}
+		rawURL = "http://" + host + "/"
+	}
+	base, err := url.Parse(rawURL)
+	if err != nil || base.Host == "" || (base.Scheme != "http" && base.Scheme != "https") {
+		return nil, fmt.Errorf("invalid mirror URL %q", rawURL)
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	a := newArchive("mirror")
+	m := &mirror{dir: dir, base: base}
+	if base.Path != "/" {
+		info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(base.Path)))
+		m.hostDir = err == nil && info.IsDir()
+	}
+	a.mirror = m
+	a.root = base.String()
+	return a, nil
+}
+
+// lookup reads the file saved for u, trying the names wget gives pages
+func (m *mirror) lookup(u *url.URL) *entry {
+	if !strings.EqualFold(u.Host, m.base.Host) {
+		return nil
+	}
+	full := path.Clean("/" + u.Path)
+	// The base path only matches whole segments: /docs/ covers /docs and
+	// /docs/a, not /docsfoo
+	rel, ok := strings.CutPrefix(full, strings.TrimSuffix(path.Clean(m.base.Path), "/"))
+	if !ok || (rel != "" && !strings.HasPrefix(rel, "/")) {
+		return nil
+	}
+	if m.hostDir {
+		rel = full
+	}
+
+	for _, name := range m.names(u, strings.TrimPrefix(rel, "/")) {
+		file := filepath.Join(m.dir, filepath.FromSlash(name))
+		info, err := os.Stat(file)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		return m.read(file)
+	}
+	return nil
+}
+
+// names lists the files wget may have saved u as, relative to the mirror
+// directory, given u's path rel within it
+func (m *mirror) names(u *url.URL, rel string) []string {
+	var names []string
+	if u.RawQuery != "" {
+		// wget keeps the query in the file name; on Windows with "@"
+		names = append(names, rel+"?"+u.RawQuery, rel+"@"+u.RawQuery)
+		if name := m.queryFile(rel, u.Query()); name != "" {
+			names = append(names, name)
+		}
+	}
+	if rel == "" || strings.HasSuffix(u.Path, "/") {
+		names = append(names, path.Join(rel, "index.html"))
+	} else {
+		// --adjust-extension saves pages without one as .html
+		names = append(names, rel, rel+".html", path.Join(rel, "index.html"))
+	}
+	return names
+}
+
+// queryFile finds the file saved for rel with query q when the parameters
+// are in a different order than the crawler asks for them
+func (m *mirror) queryFile(rel string, q url.Values) string {
+	dir, base := path.Split(rel)
+	files, err := os.ReadDir(filepath.Join(m.dir, filepath.FromSlash(dir)))
+	if err != nil {
+		return ""
+	}
+	want := q.Encode()
+	for _, f := range files {
+		name := f.Name()
+		rest, ok := strings.CutPrefix(name, base)
+		if !ok || len(rest) < 2 || (rest[0] != '?' && rest[0] != '@') {
+			continue
+		}
+		if saved, err := url.ParseQuery(rest[1:]); err == nil && saved.Encode() == want {
+			return dir + name
+		}
+	}
+	return ""
+}
+
+func (m *mirror) read(file string) *entry {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	body, err := io.ReadAll(io.LimitReader(f, maxBody))
+	if err != nil {
+		return nil
+	}
+
+	// Names with a query have no reliable extension; sniff those
+	ct := ""
+	if name := filepath.Base(file); !strings.ContainsAny(name, "?@") {
+		ct = mime.TypeByExtension(filepath.Ext(name))
+	}
+	if ct == "" {
+		ct = http.DetectContentType(body)
+	}
+	return &entry{
+		status: http.StatusOK,
+		header: http.Header{"Content-Type": {ct}},
+		body:   body,
+	}
+}
@@ -0,0 +1,119 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// openWARC reads the response records of a WARC file (ISO 28500), plain or
+// gzipped. A .warc.gz is a series of gzip members, one per record, which
+// gzip.Reader reads as one stream.
+func openWARC(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	br := bufio.NewReaderSize(f, 64*1024)
+	var r *bufio.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		defer gz.Close()
+		r = bufio.NewReaderSize(gz, 64*1024)
+	}
+
+	a := newArchive("warc")
+	for n := 1; ; n++ {
+		err := readWARCRecord(r, a)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: record %d: %w", path, n, err)
+		}
+	}
+	return a, nil
+}
+
+// readWARCRecord reads one record and adds it to a when it is an HTTP
+// response. It returns io.EOF at the end of the file.
+func readWARCRecord(r *bufio.Reader, a *Archive) error {
+	// Skip the blank lines that end the previous record
+	var version string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if line == "" || strings.TrimSpace(line) == "" {
+				return io.EOF
+			}
+			return io.ErrUnexpectedEOF
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			version = line
+			break
+		}
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return fmt.Errorf("expected a WARC version line, got %q", version)
+	}
+
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return err
+	}
+	length, err := strconv.ParseInt(header.Get("Content-Length"), 10, 64)
+	if err != nil || length < 0 {
+		return fmt.Errorf("bad Content-Length %q", header.Get("Content-Length"))
+	}
+	block := io.LimitReader(r, length)
+	defer io.Copy(io.Discard, block)
+
+	if header.Get("WARC-Type") != "response" {
+		return nil
+	}
+	if !strings.HasPrefix(strings.ToLower(header.Get("Content-Type")), "application/http") {
+		return nil
+	}
+	target := strings.Trim(header.Get("WARC-Target-URI"), "<>")
+
+	resp, err := http.ReadResponse(bufio.NewReader(block), nil)
+	if err != nil {
+		// A damaged capture of one page shouldn't lose the rest
+		return nil
+	}
+	defer resp.Body.Close()
+
+	e := &entry{status: resp.StatusCode, header: resp.Header}
+	if keepBody(resp.Header.Get("Content-Type")) {
+		var body io.Reader = resp.Body
+		// WARC keeps the body as it came off the wire, compressed or not
+		if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+			gz, err := gzip.NewReader(resp.Body)
+			if err != nil {
+				return nil
+			}
+			defer gz.Close()
+			body = gz
+		}
+		e.body, _ = io.ReadAll(io.LimitReader(body, maxBody))
+	}
+	// The body is stored decoded; the headers must not claim otherwise
+	e.header.Del("Content-Encoding")
+	e.header.Del("Transfer-Encoding")
+	e.header.Del("Content-Length")
+	a.add(target, e)
+	return nil
+}
@@ -0,0 +1,156 @@
+// Package cache stores scan results on disk so unchanged text is not scanned
+// twice. Entries are keyed on the content hash, the detector's rule set hash,
+// the detector schema and the tool version, so new presets, new matching
+// behavior or a new release never see stale results.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/QRY91/slopsquid/internal/detector"
+)
+
+// layout is bumped if the on-disk format changes, so old entries are ignored
+// rather than misread
+const layout = "v1"
+
+// Cache maps scanned text to its ScanResult. A nil *Cache is valid and
+// caches nothing.
+type Cache struct {
+	dir     string
+	salt    string
+	Hits    int
+	Misses  int
+	Written int
+}
+
+// DefaultDir returns $XDG_CACHE_HOME/slopsquid, or the platform cache
+// directory
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("locating cache directory: %w", err)
+	}
+	return filepath.Join(dir, "slopsquid"), nil
+}
+
+// Open returns a cache in dir for results produced by the given rule set and
+// tool version under the current detector.Schema
+func Open(dir, ruleSetHash, version string) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Join(dir, layout), 0o755); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	salt := fmt.Sprintf("%s\x00%d\x00%s", ruleSetHash, detector.Schema, version)
+	return &Cache{dir: dir, salt: salt}, nil
+}
+
+// key hashes the text together with the rule set, schema and version
+func (c *Cache) key(text string) string {
+	content := sha256.Sum256([]byte(text))
+	sum := sha256.Sum256([]byte(c.salt + "\x00" + hex.EncodeToString(content[:])))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, layout, key[:2], key+".json")
+}
+
+// Get returns the cached result for text, if any. Unreadable entries count
+// as misses.
+func (c *Cache) Get(text string) (*detector.ScanResult, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(c.key(text)))
+	if err != nil {
+		c.Misses++
+		return nil, false
+	}
+	var result detector.ScanResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		c.Misses++
+		return nil, false
+	}
+	c.Hits++
+	return &result, true
+}
+
+// Put stores the result for text. The entry is written to a temporary file
+// and renamed so concurrent runs never see a partial entry.
+func (c *Cache) Put(text string, result *detector.ScanResult) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(c.key(text))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	c.Written++
+	return nil
+}
+
+// Stats counts the entries and bytes stored under dir
+func Stats(dir string) (entries int, size int64, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		entries++
+		size += info.Size()
+		return nil
+	})
+	return entries, size, err
+}
+
+// Clean removes every entry under dir and returns how many were removed.
+// Only the cache's own subdirectory is deleted, so pointing dir at a shared
+// directory is safe.
+func Clean(dir string) (int, error) {
+	root := filepath.Join(dir, layout)
+	entries, _, err := Stats(root)
+	if err != nil {
+		return 0, err
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return 0, fmt.Errorf("removing cache: %w", err)
+	}
+	return entries, nil
+}
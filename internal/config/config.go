@@ -0,0 +1,78 @@
+// Package config loads project settings from a .slopsquid.json file, so a
+// repository can pin its scan rules instead of repeating them on every
+// command line.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// FileName is looked up from the working directory upwards
+const FileName = ".slopsquid.json"
+
+// Config is the contents of a .slopsquid.json file. Command-line flags add
+// to the lists here rather than replacing them.
+type Config struct {
+	Include  []string `json:"include,omitempty"`   // doublestar globs a file must match
+	Exclude  []string `json:"exclude,omitempty"`   // doublestar globs to skip
+	NoIgnore bool     `json:"no_ignore,omitempty"` // don't honor .gitignore files
+
+	Crawl Crawl `json:"crawl"`
+
+	path string
+}
+
+// Crawl limits which URLs report crawls
+type Crawl struct {
+	Hosts        []string `json:"hosts,omitempty"`         // more hosts to crawl; "*.example.com" for subdomains
+	IncludePaths []string `json:"include_paths,omitempty"` // path prefixes a URL must start with
+	ExcludePaths []string `json:"exclude_paths,omitempty"` // path prefixes to skip
+	IncludeURLs  []string `json:"include_urls,omitempty"`  // regular expressions a URL must match
+	ExcludeURLs  []string `json:"exclude_urls,omitempty"`  // regular expressions to skip
+}
+
+// Path returns the file the config was loaded from
+func (c *Config) Path() string {
+	return c.path
+}
+
+// Find returns the nearest .slopsquid.json in start or its parents
+func Find(start string) (string, bool) {
+	dir, err := filepath.Abs(start)
+	if err != nil {
+		return "", false
+	}
+	for {
+		path := filepath.Join(dir, FileName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Load reads a config file. Unknown keys are an error so a typo doesn't
+// silently do nothing.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	var c Config
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	c.path = path
+	return &c, nil
+}
@@ -1,14 +1,19 @@
 package crawler
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"os"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/QRY91/slopsquid/internal/scanner"
 )
 
 // Page represents a crawled page with extracted text
@@ -16,8 +21,24 @@ type Page struct {
 	URL        string `json:"url"`
 	StatusCode int    `json:"status_code"`
 	Text       string `json:"text,omitempty"`
-	rawHTML    string // unexported, used for link extraction
+	raw        string // unexported, used for link extraction
+	format     string // the scanner's name for the page's format
+	finalURL   *url.URL
 	Error      string `json:"error,omitempty"`
+	Retries    int    `json:"retries,omitempty"` // attempts after the first
+
+	// Canonical is set when the page names another URL as canonical (or
+	// redirected to one) that could not be fetched in its place
+	Canonical string `json:"canonical,omitempty"`
+	// Aliases are other URLs that served this page: the fetched URL when it
+	// was reported under its canonical one, and exact duplicates
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Validators for conditional re-crawls, and whether the server answered
+	// 304 so the text is the previous crawl's
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+	NotModified  bool   `json:"not_modified,omitempty"`
 }
 
 // Options configures crawler behavior
@@ -25,23 +46,69 @@ type Options struct {
 	MaxDepth    int
 	MaxPages    int
 	Concurrency int
-	Delay       time.Duration
-	UserAgent   string
-	Verbose     bool
+	// Delay is the interval between requests to one host, shared by all
+	// workers. Burst lets that many requests go out back to back first.
+	// A negative Delay sends requests unspaced and ignores Crawl-delay.
+	Delay     time.Duration
+	Burst     int
+	UserAgent string
+	Verbose   bool
+	// Retries is how often a timeout, 429 or 5xx is retried, with
+	// exponential backoff or as long as Retry-After asks. -1 disables.
+	Retries int
+
+	// Sitemaps overrides sitemap discovery with these URLs or local files
+	Sitemaps []string
+	// Since skips sitemap pages whose lastmod is older, for incremental
+	// crawls. Pages without a lastmod are always crawled.
+	Since time.Time
+	// Scope widens the crawl to more hosts or narrows it to some URLs
+	Scope Scope
+	// State, when set, journals the crawl so it can be resumed, and makes
+	// requests conditional on the previous crawl's validators
+	State *State
+	// Transport, when set, answers requests instead of the network, as an
+	// archive.Archive does for offline crawls
+	Transport http.RoundTripper
+	// OnPage, when set, is called with each page Crawl will return as soon
+	// as it is final, on the goroutine running Crawl. Aliases found later
+	// are still added to the page.
+	OnPage func(p *Page)
 }
 
 // Crawler fetches pages from a website and extracts text
 type Crawler struct {
-	opts       Options
-	base       *url.URL
-	visited    map[string]bool
-	mu         sync.Mutex
-	client     *http.Client
-	disallowed []string // robots.txt disallow rules
+	opts    Options
+	base    *url.URL
+	visited map[string]bool
+	mu      sync.Mutex
+	client  *http.Client
+	robots  *robots // the root host's
+	limiter *limiter
+	scope   *scope
+
+	// robots.txt of the other hosts in scope, fetched on first use
+	hostRobots map[string]*robots
+	robotsMu   sync.Mutex
+
+	unchanged int    // sitemap pages skipped as older than Options.Since
+	skipped   []Skip // URLs found but not fetched
+
+	// rootSkip is why the root URL is outside the scope's paths or
+	// patterns. Such a root is still fetched, for its links, but isn't
+	// reported as a page.
+	rootSkip string
 }
 
 var linkRegex = regexp.MustCompile(`(?i)href\s*=\s*["']([^"'#]+)["']`)
 
+// mdLinkRegex finds the targets of Markdown links and images
+var mdLinkRegex = regexp.MustCompile(`\]\(\s*<?([^)\s>#]+)`)
+
+// acceptTypes are the formats fetch reads, HTML first
+const acceptTypes = "text/html, application/xhtml+xml, text/markdown, " +
+	"application/rss+xml, application/atom+xml, text/plain;q=0.9, application/xml;q=0.8, text/xml;q=0.8"
+
 // New creates a crawler rooted at the given URL
 func New(rootURL string, opts Options) (*Crawler, error) {
 	u, err := url.Parse(rootURL)
@@ -62,8 +129,19 @@ func New(rootURL string, opts Options) (*Crawler, error) {
 	if opts.Concurrency == 0 {
 		opts.Concurrency = 3
 	}
+	unspaced := opts.Delay < 0
 	if opts.Delay == 0 {
 		opts.Delay = 200 * time.Millisecond
+	} else if unspaced {
+		opts.Delay = 0
+	}
+	if opts.Burst == 0 {
+		opts.Burst = 1
+	}
+	if opts.Retries == 0 {
+		opts.Retries = 3
+	} else if opts.Retries < 0 {
+		opts.Retries = 0
 	}
 	if opts.UserAgent == "" {
 		opts.UserAgent = "SlopSquid/0.3"
@@ -74,7 +152,8 @@ func New(rootURL string, opts Options) (*Crawler, error) {
 		base:    u,
 		visited: make(map[string]bool),
 		client: &http.Client{
-			Timeout: 15 * time.Second,
+			Transport: opts.Transport,
+			Timeout:   15 * time.Second,
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				if len(via) >= 5 {
 					return fmt.Errorf("too many redirects")
@@ -84,160 +163,495 @@ func New(rootURL string, opts Options) (*Crawler, error) {
 		},
 	}
 
-	// Fetch and parse robots.txt
-	c.disallowed = c.fetchRobotsTxt()
+	c.scope, err = compileScope(opts.Scope, u)
+	if err != nil {
+		return nil, err
+	}
+	c.rootSkip = c.scope.check(u)
+	c.hostRobots = make(map[string]*robots)
+
+	// Fetch and parse robots.txt; a Crawl-delay longer than ours wins
+	c.robots = c.fetchRobotsTxt(context.Background(), u.Scheme, u.Host)
+	if c.robots.crawlDelay > c.opts.Delay && !unspaced {
+		c.opts.Delay = c.robots.crawlDelay
+	}
+	c.limiter = newLimiter(c.opts.Delay, c.opts.Burst)
 
 	return c, nil
 }
 
-// Crawl starts from the root URL and returns all discovered pages
-func (c *Crawler) Crawl(progress func(n int, url string)) ([]*Page, error) {
+// task is a URL waiting in the frontier
+type task struct {
+	url   string
+	depth int
+	seed  bool // fetched only for its links
+}
+
+// fetched is a finished task and the links found on its page
+type fetched struct {
+	task
+	page  *Page
+	links []string
+}
+
+// Crawl fetches pages breadth-first from the sitemap seeds and the root URL,
+// following links up to MaxDepth and fetching at most MaxPages pages.
+//
+// A single goroutine owns the frontier and the visited set; workers only
+// fetch and extract links. When ctx is cancelled no new fetches start,
+// in-flight requests are aborted, and Crawl returns the pages finished so far
+// together with ctx.Err().
+func (c *Crawler) Crawl(ctx context.Context, progress func(n int, url string)) ([]*Page, error) {
+	var frontier []task
 	var pages []*Page
-	var pagesMu sync.Mutex
+	dedup := newDeduper()
+	done := 0
+
+	final := func(ps []*Page) {
+		pages = append(pages, ps...)
+		if c.opts.OnPage != nil {
+			for _, p := range ps {
+				c.opts.OnPage(p)
+			}
+		}
+	}
 
-	type task struct {
-		url   string
-		depth int
+	// Pick up an interrupted crawl where it stopped
+	if c.opts.State != nil {
+		var resumed []*Page
+		frontier, resumed, done = c.resume(dedup)
+		final(resumed)
 	}
 
-	queue := make(chan task, c.opts.MaxPages)
+	// A root outside the included paths is claimed before the sitemaps can
+	// skip it
+	root := cleanURL(c.base.String())
+	var seed []task
+	if c.rootSkip != "" && c.admitSeed(ctx, root) {
+		seed = append(seed, task{url: root, seed: true})
+	}
 
-	// Seed from sitemap if available
-	sitemapURLs := c.fetchSitemap()
-	for _, u := range sitemapURLs {
-		if !c.isDisallowed(u) {
-			if c.tryVisit(u) {
-				queue <- task{url: u, depth: 0}
+	// Seed from sitemaps. Pages older than Since are marked visited without
+	// being fetched, so links to them aren't followed either. They are
+	// reported as the last finished crawl saw them, when it did.
+	for _, e := range c.loadSitemaps(ctx, c.sitemapSources()) {
+		u := cleanURL(e.URL)
+		if !c.opts.Since.IsZero() && !e.LastMod.IsZero() && e.LastMod.Before(c.opts.Since) {
+			if p := c.restore(u); p != nil {
+				final(dedup.add(c, p))
 			}
+			continue
+		}
+		if c.admit(ctx, u, 0) {
+			frontier = append(frontier, task{url: u})
 		}
 	}
 
-	// Always include the root
-	if c.tryVisit(c.base.String()) {
-		queue <- task{url: c.base.String(), depth: 0}
+	// Always include the root, unless robots.txt forbids it
+	if c.rootSkip == "" && c.admit(ctx, root, 0) {
+		frontier = append(frontier, task{url: root})
 	}
+	frontier = append(frontier, seed...)
 
-	var wg sync.WaitGroup
-	sem := make(chan struct{}, c.opts.Concurrency)
-
-	active := len(c.visited) // track pending work
+	results := make(chan fetched)
+	started, inflight := done, 0
 
-	for active > 0 {
-		select {
-		case t := <-queue:
-			sem <- struct{}{}
-			wg.Add(1)
+	for {
+		// Hand out work until the worker pool, the page budget or the
+		// frontier runs out
+		for ctx.Err() == nil && inflight < c.opts.Concurrency && started < c.opts.MaxPages && len(frontier) > 0 {
+			t := frontier[0]
+			frontier = frontier[1:]
+			started++
+			inflight++
 			go func(t task) {
-				defer wg.Done()
-				defer func() { <-sem }()
+				results <- c.work(ctx, t)
+			}(t)
+		}
+		if inflight == 0 {
+			break
+		}
 
-				if c.opts.Delay > 0 {
-					time.Sleep(c.opts.Delay)
-				}
+		r := <-results
+		inflight--
+		if ctx.Err() != nil {
+			// Requests cut short by cancellation are not real results
+			continue
+		}
 
-				page := c.fetch(t.url)
+		done++
+		if progress != nil {
+			progress(done, r.url)
+		}
+		c.opts.State.record(stateRecord{Kind: "page", URL: r.url, Depth: r.depth, Page: r.page, Links: r.links})
+		if !r.seed || seedReported(r.page) {
+			c.unskip(r.url)
+			final(dedup.add(c, r.page))
+		}
+		for _, link := range r.links {
+			if c.admit(ctx, link, r.depth+1) {
+				frontier = append(frontier, task{url: link, depth: r.depth + 1})
+			}
+		}
+	}
+	c.opts.State.finish(ctx.Err() == nil)
 
-				pagesMu.Lock()
-				pages = append(pages, page)
-				count := len(pages)
-				pagesMu.Unlock()
+	// What's left in the frontier is listed, but not journaled: a resumed
+	// crawl still wants it
+	reason := "page limit reached"
+	if ctx.Err() != nil {
+		reason = "crawl interrupted"
+	}
+	for _, t := range frontier {
+		c.skipped = append(c.skipped, Skip{URL: t.url, Reason: reason})
+	}
 
-				if progress != nil {
-					progress(count, t.url)
-				}
+	final(dedup.flush())
+	return pages, ctx.Err()
+}
+
+// resume replays the journal of an unfinished crawl: the visited set, skips
+// and fetched pages are restored, and URLs queued but never fetched form the
+// frontier again
+func (c *Crawler) resume(dedup *deduper) (frontier []task, pages []*Page, fetched int) {
+	journal := c.opts.State.journal
+	done := make(map[string]bool)
+	for _, rec := range journal {
+		if rec.Kind == "page" {
+			done[rec.URL] = true
+		}
+	}
 
-				// Extract and queue links if we haven't hit depth limit
-				if page.Error == "" && t.depth < c.opts.MaxDepth {
-					links := c.extractLinks(page.rawHTML, t.url)
-					for _, link := range links {
-						if c.isDisallowed(link) {
-							continue
-						}
-						if c.tryVisit(link) {
-							pagesMu.Lock()
-							n := len(pages)
-							pagesMu.Unlock()
-							if n < c.opts.MaxPages {
-								c.mu.Lock()
-								active++
-								c.mu.Unlock()
-								queue <- task{url: link, depth: t.depth + 1}
-							}
-						}
-					}
+	for _, rec := range journal {
+		switch rec.Kind {
+		case "queue":
+			c.tryVisit(rec.URL)
+			if !done[rec.URL] {
+				frontier = append(frontier, task{url: rec.URL, depth: rec.Depth, seed: c.isSeed(rec.URL)})
+			}
+		case "skip":
+			c.tryVisit(rec.URL)
+			c.skipped = append(c.skipped, Skip{URL: rec.URL, Reason: rec.Reason})
+		case "page":
+			if rec.Page != nil {
+				fetched++
+				if !c.isSeed(rec.URL) || seedReported(rec.Page) {
+					c.unskip(rec.URL)
+					pages = append(pages, dedup.add(c, rec.Page)...)
 				}
+			}
+		}
+	}
+	return frontier, pages, fetched
+}
 
-				c.mu.Lock()
-				active--
-				c.mu.Unlock()
-			}(t)
-		default:
-			// Let goroutines finish
-			time.Sleep(50 * time.Millisecond)
-			c.mu.Lock()
-			a := active
-			c.mu.Unlock()
-			if a == 0 {
+// admit decides whether a discovered URL joins the frontier at depth,
+// recording why when it doesn't. Each page is decided once.
+func (c *Crawler) admit(ctx context.Context, rawURL string, depth int) bool {
+	if !c.tryVisit(rawURL) {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		c.skip(rawURL, "invalid URL")
+		return false
+	}
+	if reason := c.scope.check(u); reason != "" {
+		c.skip(rawURL, reason)
+		return false
+	}
+	r := c.robotsFor(ctx, u)
+	if r == nil {
+		// Cancelled before robots.txt was read; a resumed crawl retries it
+		return false
+	}
+	if !r.allowed(u) {
+		c.skip(rawURL, "disallowed by robots.txt")
+		return false
+	}
+	c.opts.State.record(stateRecord{Kind: "queue", URL: rawURL, Depth: depth})
+	return true
+}
+
+// restore returns the saved copy of a sitemap page skipped as unchanged
+// since Options.Since, journaling it so the next crawl can restore it too.
+// Without one the page is listed as skipped.
+func (c *Crawler) restore(rawURL string) *Page {
+	if !c.tryVisit(rawURL) {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		c.skip(rawURL, "invalid URL")
+		return nil
+	}
+	if reason := c.scope.check(u); reason != "" {
+		c.skip(rawURL, reason)
+		return nil
+	}
+
+	prev, ok := c.opts.State.lookup(rawURL)
+	if !ok || prev.Page == nil || prev.Page.Error != "" {
+		c.unchanged++
+		c.skip(rawURL, "unchanged since "+c.opts.Since.Format("2006-01-02 15:04"))
+		return nil
+	}
+	page := *prev.Page
+	page.URL = rawURL
+	page.Retries = 0
+	page.NotModified = true
+	c.opts.State.record(stateRecord{Kind: "queue", URL: rawURL})
+	c.opts.State.record(stateRecord{Kind: "page", URL: rawURL, Page: &page, Links: prev.Links})
+	return &page
+}
+
+// admitSeed queues a root that is out of scope to be fetched for its links
+// only. It is listed as skipped, since it isn't reported.
+func (c *Crawler) admitSeed(ctx context.Context, root string) bool {
+	if !c.tryVisit(root) {
+		return false
+	}
+	u, _ := url.Parse(root)
+	r := c.robotsFor(ctx, u)
+	if r == nil {
+		return false
+	}
+	if !r.allowed(u) {
+		c.skip(root, "disallowed by robots.txt")
+		return false
+	}
+	c.opts.State.record(stateRecord{Kind: "queue", URL: root})
+	c.skip(root, c.rootSkip+"; followed for links only")
+	return true
+}
+
+// isSeed reports whether rawURL is a root fetched only for its links
+func (c *Crawler) isSeed(rawURL string) bool {
+	return c.rootSkip != "" && urlKey(rawURL) == urlKey(cleanURL(c.base.String()))
+}
+
+// seedReported reports whether a root fetched for its links is reported
+// anyway: when it failed, so the failure is seen, or when it redirected to
+// (or names as canonical) a page in scope
+func seedReported(p *Page) bool {
+	return p.Error != "" || p.Canonical != ""
+}
+
+func (c *Crawler) skip(rawURL, reason string) {
+	c.skipped = append(c.skipped, Skip{URL: rawURL, Reason: reason})
+	c.opts.State.record(stateRecord{Kind: "skip", URL: rawURL, Reason: reason})
+}
+
+// unskip takes back the skip of a root that turned out to be reported
+func (c *Crawler) unskip(rawURL string) {
+	if !c.isSeed(rawURL) {
+		return
+	}
+	c.skipped = slices.DeleteFunc(c.skipped, func(s Skip) bool { return s.URL == rawURL })
+}
+
+// Skipped returns the URLs the last crawl found but did not fetch, with the
+// reason for each
+func (c *Crawler) Skipped() []Skip {
+	return c.skipped
+}
+
+// work fetches t, retrying transient failures, and extracts its links
+func (c *Crawler) work(ctx context.Context, t task) fetched {
+	r := fetched{task: t}
+	host := hostOf(t.url)
+	prev, hasPrev := c.opts.State.lookup(t.url)
+
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.wait(ctx, host); err != nil {
+			r.page = &Page{URL: t.url, Error: err.Error(), Retries: attempt}
+			return r
+		}
+
+		var validators *Page
+		if hasPrev {
+			validators = prev.Page
+		}
+		page, retry := c.fetch(ctx, t.url, validators)
+		page.Retries = attempt
+		r.page = page
+		if !retry.transient || attempt >= c.opts.Retries || ctx.Err() != nil {
+			break
+		}
+
+		wait := backoff(attempt)
+		if retry.after > 0 {
+			if retry.after > retryAfterMax {
 				break
 			}
+			wait = retry.after
 		}
-
-		c.mu.Lock()
-		a := active
-		c.mu.Unlock()
-		if a == 0 {
+		// The server is asking us to slow down, so every worker holds off
+		if page.StatusCode == http.StatusTooManyRequests || page.StatusCode == http.StatusServiceUnavailable {
+			c.limiter.pause(host, time.Now().Add(wait))
+		}
+		if c.opts.Verbose {
+			fmt.Fprintf(os.Stderr, "  retry %d for %s in %s: %s\n", attempt+1, t.url, wait.Round(time.Millisecond), page.Error)
+		}
+		if err := sleep(ctx, wait); err != nil {
 			break
 		}
 	}
 
-	wg.Wait()
+	if r.page.Error != "" {
+		return r
+	}
+	if r.page.NotModified {
+		// Same page as last time, so the same links
+		if t.depth < c.opts.MaxDepth {
+			r.links = prev.Links
+		}
+		return r
+	}
 
-	return pages, nil
+	// Links resolve against where a redirect ended, and a page that names
+	// (or redirected to) another URL on the site is folded into it
+	final := r.page.finalURL
+	var canonical string
+	if r.page.format == "html" || r.page.format == "xhtml" {
+		canonical = extractCanonical(r.page.raw, final)
+	}
+	if canonical == "" || !c.inScope(canonical) {
+		canonical = normalizeURL(final).String()
+	}
+	if c.inScope(canonical) && urlKey(canonical) != urlKey(t.url) {
+		r.page.Canonical = canonical
+	}
+	if t.depth < c.opts.MaxDepth {
+		r.links = c.extractLinks(r.page, final.String())
+	}
+	return r
 }
 
-func (c *Crawler) fetch(pageURL string) *Page {
-	req, err := http.NewRequest("GET", pageURL, nil)
+// retryHint says whether a failed fetch may succeed if tried again
+type retryHint struct {
+	transient bool
+	after     time.Duration // from Retry-After; zero when absent
+}
+
+// fetch requests pageURL. With prev, the previous crawl's copy of the page,
+// the request is conditional and a 304 returns that copy.
+func (c *Crawler) fetch(ctx context.Context, pageURL string, prev *Page) (*Page, retryHint) {
+	req, err := http.NewRequestWithContext(ctx, "GET", pageURL, nil)
 	if err != nil {
-		return &Page{URL: pageURL, Error: err.Error()}
+		return &Page{URL: pageURL, Error: err.Error()}, retryHint{}
 	}
 	req.Header.Set("User-Agent", c.opts.UserAgent)
-	req.Header.Set("Accept", "text/html")
+	req.Header.Set("Accept", acceptTypes)
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return &Page{URL: pageURL, Error: err.Error()}
+		// Network errors and timeouts are transient; cancellation is not
+		return &Page{URL: pageURL, Error: err.Error()}, retryHint{transient: ctx.Err() == nil}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		page := *prev
+		page.URL = pageURL
+		page.Retries = 0
+		page.NotModified = true
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			page.ETag = etag
+		}
+		return &page, retryHint{}
+	}
+
 	if resp.StatusCode != 200 {
-		return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+		page := &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: fmt.Sprintf("HTTP %d", resp.StatusCode)}
+		hint := retryHint{transient: transientStatus(resp.StatusCode)}
+		if hint.transient {
+			hint.after, _ = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return page, hint
 	}
 
-	ct := resp.Header.Get("Content-Type")
-	if !strings.Contains(ct, "text/html") && !strings.Contains(ct, "text/plain") {
-		return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: "not text content"}
+	format := pageFormat(resp.Header.Get("Content-Type"), resp.Request.URL.Path)
+	if format == "" {
+		return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: "not text content"}, retryHint{}
 	}
 
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 5*1024*1024))
 	if err != nil {
-		return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: err.Error()}
+		// The connection dropped mid-body
+		return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: err.Error()}, retryHint{transient: ctx.Err() == nil}
 	}
 
 	raw := string(body)
-	text := ExtractText(raw)
+	if format == "xml" {
+		// XML is only read when it is a feed served under a generic type
+		if !scanner.IsFeed(raw) {
+			return &Page{URL: pageURL, StatusCode: resp.StatusCode, Error: "not text content"}, retryHint{}
+		}
+		format = "feed"
+	}
+	var text string
+	if format == "html" || format == "xhtml" {
+		text = ExtractText(raw)
+	} else {
+		text = scanner.ExtractText(format, raw)
+	}
 
 	return &Page{
 		URL:        pageURL,
 		StatusCode: resp.StatusCode,
 		Text:       text,
-		rawHTML:    raw,
+		raw:        raw,
+		format:     format,
+		finalURL:   resp.Request.URL,
+
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}, retryHint{}
+}
+
+// pageFormat picks how to read a response from its Content-Type. Plain
+// text named like Markdown, reStructuredText or AsciiDoc is read as such,
+// since servers often send those as text/plain. "" means it isn't text.
+func pageFormat(contentType, path string) string {
+	format := scanner.TypeForContentType(contentType)
+	if format == "text" {
+		switch byName := scanner.FileType(path); byName {
+		case "markdown", "restructuredtext", "asciidoc":
+			return byName
+		}
 	}
+	return format
 }
 
-// extractLinks finds same-domain links in HTML content
-func (c *Crawler) extractLinks(html string, pageURL string) []string {
+// hostOf returns the host of rawURL, which the rate limit is keyed on
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// extractLinks finds links to pages in HTML or Markdown content. Whether
+// they are in scope is decided when they reach the frontier.
+func (c *Crawler) extractLinks(page *Page, pageURL string) []string {
 	pageU, _ := url.Parse(pageURL)
-	matches := linkRegex.FindAllStringSubmatch(html, -1)
+	var matches [][]string
+	switch page.format {
+	case "html", "xhtml":
+		matches = linkRegex.FindAllStringSubmatch(page.raw, -1)
+	case "markdown":
+		matches = mdLinkRegex.FindAllStringSubmatch(page.raw, -1)
+	}
 
 	var links []string
 	seen := make(map[string]bool)
@@ -252,29 +666,21 @@ func (c *Crawler) extractLinks(html string, pageURL string) []string {
 		}
 		resolved := pageU.ResolveReference(u)
 
-		// Same domain only
-		if resolved.Host != c.base.Host {
-			continue
-		}
-
 		// Only HTTP(S)
 		if resolved.Scheme != "http" && resolved.Scheme != "https" {
 			continue
 		}
 
-		// Strip fragment
-		resolved.Fragment = ""
-
 		// Skip non-page resources
 		path := strings.ToLower(resolved.Path)
 		if isAssetPath(path) {
 			continue
 		}
 
-		canonical := resolved.String()
-		if !seen[canonical] {
-			seen[canonical] = true
-			links = append(links, canonical)
+		link := normalizeURL(resolved).String()
+		if !seen[link] {
+			seen[link] = true
+			links = append(links, link)
 		}
 	}
 
@@ -284,7 +690,7 @@ func (c *Crawler) extractLinks(html string, pageURL string) []string {
 func isAssetPath(path string) bool {
 	exts := []string{".css", ".js", ".png", ".jpg", ".jpeg", ".gif", ".svg", ".ico",
 		".woff", ".woff2", ".ttf", ".eot", ".mp3", ".mp4", ".webm", ".webp",
-		".pdf", ".zip", ".tar", ".gz", ".xml", ".json", ".rss", ".atom"}
+		".pdf", ".zip", ".tar", ".gz", ".json"}
 	for _, ext := range exts {
 		if strings.HasSuffix(path, ext) {
 			return true
@@ -295,180 +701,148 @@ func isAssetPath(path string) bool {
 
 // robots.txt support
 
-func (c *Crawler) fetchRobotsTxt() []string {
-	robotsURL := fmt.Sprintf("%s://%s/robots.txt", c.base.Scheme, c.base.Host)
+// fetchRobotsTxt fetches and parses robots.txt. Per RFC 9309, a missing file
+// (4xx) allows everything and an unreachable one (5xx or network failure)
+// disallows everything. It returns nil if ctx ends before the file is read.
+func (c *Crawler) fetchRobotsTxt(ctx context.Context, scheme, host string) *robots {
+	robotsURL := fmt.Sprintf("%s://%s/robots.txt", scheme, host)
 
-	req, err := http.NewRequest("GET", robotsURL, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", robotsURL, nil)
 	if err != nil {
-		return nil
+		return &robots{}
 	}
 	req.Header.Set("User-Agent", c.opts.UserAgent)
 
 	resp, err := c.client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return nil
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return &robots{disallowed: true}
 	}
 	defer resp.Body.Close()
 
+	switch {
+	case resp.StatusCode >= 500:
+		return &robots{disallowed: true}
+	case resp.StatusCode != 200:
+		return &robots{}
+	}
+
 	body, err := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
 	if err != nil {
-		return nil
+		if ctx.Err() != nil {
+			return nil
+		}
+		return &robots{disallowed: true}
 	}
 
 	return parseRobotsTxt(string(body), c.opts.UserAgent)
 }
 
-// parseRobotsTxt extracts Disallow rules that apply to our user agent
-func parseRobotsTxt(content string, userAgent string) []string {
-	var disallowed []string
-	lines := strings.Split(content, "\n")
-
-	// Two-pass: first look for our specific user-agent block,
-	// then fall back to * block
-	uaLower := strings.ToLower(userAgent)
-	var inOurBlock, inStarBlock bool
-	var ourRules, starRules []string
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Strip comments
-		if idx := strings.Index(line, "#"); idx >= 0 {
-			line = strings.TrimSpace(line[:idx])
+// robotsFor returns the robots.txt rules for u's host. Hosts other than the
+// root's are fetched on first use, and their Crawl-delay applies to them
+// alone. It returns nil, and remembers nothing, if ctx ends during the fetch.
+func (c *Crawler) robotsFor(ctx context.Context, u *url.URL) *robots {
+	host := normalizeURL(u).Host
+	if host == normalizeURL(c.base).Host {
+		return c.robots
+	}
+
+	c.robotsMu.Lock()
+	defer c.robotsMu.Unlock()
+	r, ok := c.hostRobots[host]
+	if !ok {
+		if r = c.fetchRobotsTxt(ctx, u.Scheme, host); r == nil {
+			return nil
 		}
-		if line == "" {
-			continue
+		c.hostRobots[host] = r
+		if c.opts.Delay > 0 {
+			c.limiter.slowDown(host, r.crawlDelay)
 		}
-
-		lower := strings.ToLower(line)
-
-		if strings.HasPrefix(lower, "user-agent:") {
-			agent := strings.TrimSpace(line[len("user-agent:"):])
-			agentLower := strings.ToLower(agent)
-
-			// Reset block tracking on new user-agent
-			inOurBlock = strings.Contains(uaLower, agentLower) || strings.Contains(agentLower, "slopsquid")
-			inStarBlock = agent == "*"
-			continue
-		}
-
-		if strings.HasPrefix(lower, "disallow:") {
-			path := strings.TrimSpace(line[len("disallow:"):])
-			if path == "" {
-				continue
-			}
-			if inOurBlock {
-				ourRules = append(ourRules, path)
-			} else if inStarBlock {
-				starRules = append(starRules, path)
-			}
-		}
-	}
-
-	// Prefer specific rules; fall back to wildcard
-	if len(ourRules) > 0 {
-		disallowed = ourRules
-	} else {
-		disallowed = starRules
 	}
-
-	return disallowed
+	return r
 }
 
-// isDisallowed checks if a URL path is blocked by robots.txt
-func (c *Crawler) isDisallowed(rawURL string) bool {
-	if len(c.disallowed) == 0 {
-		return false
-	}
-
+// inScope reports whether rawURL may be crawled under Options.Scope
+func (c *Crawler) inScope(rawURL string) bool {
 	u, err := url.Parse(rawURL)
-	if err != nil {
-		return false
-	}
-	path := u.Path
-
-	for _, rule := range c.disallowed {
-		// Exact prefix match (standard robots.txt behavior)
-		if strings.HasPrefix(path, rule) {
-			return true
-		}
-		// Wildcard support (e.g., "/*.pdf$")
-		if strings.Contains(rule, "*") {
-			pattern := strings.ReplaceAll(regexp.QuoteMeta(rule), `\*`, `.*`)
-			if strings.HasSuffix(pattern, `\$`) {
-				pattern = strings.TrimSuffix(pattern, `\$`) + "$"
-			}
-			if re, err := regexp.Compile(pattern); err == nil && re.MatchString(path) {
-				return true
-			}
-		}
-	}
-
-	return false
+	return err == nil && c.scope.check(u) == ""
 }
 
-// sitemap support
-
-var locRegex = regexp.MustCompile(`<loc>([^<]+)</loc>`)
-
-func (c *Crawler) fetchSitemap() []string {
-	sitemapURL := fmt.Sprintf("%s://%s/sitemap.xml", c.base.Scheme, c.base.Host)
-
-	req, err := http.NewRequest("GET", sitemapURL, nil)
-	if err != nil {
-		return nil
-	}
-	req.Header.Set("User-Agent", c.opts.UserAgent)
-
-	resp, err := c.client.Do(req)
-	if err != nil || resp.StatusCode != 200 {
-		return nil
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(io.LimitReader(resp.Body, 2*1024*1024))
-	if err != nil {
-		return nil
-	}
+// Delay returns the wait between requests, which robots.txt may have raised
+func (c *Crawler) Delay() time.Duration {
+	return c.opts.Delay
+}
 
-	matches := locRegex.FindAllStringSubmatch(string(body), -1)
-	var urls []string
-	for _, m := range matches {
-		u := strings.TrimSpace(m[1])
-		if u != "" && !isAssetPath(strings.ToLower(u)) {
-			urls = append(urls, u)
-		}
-	}
+// RobotsBlocked reports whether robots.txt could not be fetched because the
+// server failed, in which case nothing is crawled
+func (c *Crawler) RobotsBlocked() bool {
+	return c.robots.disallowed
+}
 
-	return urls
+// Unchanged returns how many sitemap pages were skipped because their
+// lastmod is older than Options.Since, and no saved copy could be reported
+func (c *Crawler) Unchanged() int {
+	return c.unchanged
 }
 
 // visited tracking
 
-func (c *Crawler) markVisited(u string) {
-	c.mu.Lock()
-	c.visited[u] = true
-	c.mu.Unlock()
-}
-
+// tryVisit marks the page behind u as visited, returning false if it already
+// was under this or an equivalent URL
 func (c *Crawler) tryVisit(u string) bool {
+	key := urlKey(u)
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	if c.visited[u] {
+	if c.visited[key] {
 		return false
 	}
-	c.visited[u] = true
+	c.visited[key] = true
 	return true
 }
 
+// cleanURL normalizes a URL string for fetching, leaving it as is when it
+// doesn't parse
+func cleanURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	return normalizeURL(u).String()
+}
+
 // ExtractText strips HTML tags and returns readable text.
 // Exported so the CLI can use it for local HTML files too.
+//
+// Pages that render in the browser carry their text in embedded JSON or a
+// <noscript> block rather than the markup. When the markup shows fewer than
+// MinWords words, the extractor finding the most words is used instead.
+// Server-rendered pages are read from their markup alone, even when their
+// embedded state holds more.
 func ExtractText(html string) string {
+	text := visibleText(html)
+	best := len(strings.Fields(text))
+	if best >= MinWords {
+		return text
+	}
+	for _, e := range extractors {
+		if t := e.Extract(html); len(strings.Fields(t)) > best {
+			text, best = t, len(strings.Fields(t))
+		}
+	}
+	return text
+}
+
+// visibleText returns the text of the page's markup, without scripts or
+// the <noscript> fallback
+func visibleText(html string) string {
 	// Remove script/style blocks
 	scriptRe := regexp.MustCompile(`(?is)<script[^>]*>.*?</script>`)
 	styleRe := regexp.MustCompile(`(?is)<style[^>]*>.*?</style>`)
 	html = scriptRe.ReplaceAllString(html, "")
 	html = styleRe.ReplaceAllString(html, "")
+	html = noscriptRegex.ReplaceAllString(html, "")
 
 	// Remove nav, header, footer — focus on article content
 	navRe := regexp.MustCompile(`(?is)<(?:nav|header|footer)[^>]*>.*?</(?:nav|header|footer)>`)
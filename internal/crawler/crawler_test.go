@@ -0,0 +1,1021 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// site serves generated HTML pages; links returns the hrefs for a path, and
+// paths it returns nil for are 404s
+func site(t *testing.T, links func(path string) []string) (*httptest.Server, *int64) {
+	t.Helper()
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" || r.URL.Path == "/sitemap.xml" {
+			http.NotFound(w, r)
+			return
+		}
+		hrefs := links(r.URL.Path)
+		if hrefs == nil {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt64(&hits, 1)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<html><body><p>Page %s</p>", r.URL.Path)
+		for _, h := range hrefs {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, h, h)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func newTestCrawler(t *testing.T, root string, opts Options) *Crawler {
+	t.Helper()
+	if opts.Delay == 0 {
+		opts.Delay = time.Millisecond
+	}
+	c, err := New(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func crawlURLs(pages []*Page) map[string]bool {
+	urls := make(map[string]bool)
+	for _, p := range pages {
+		urls[p.URL] = true
+	}
+	return urls
+}
+
+func TestCrawlDeep(t *testing.T) {
+	// A chain /0 -> /1 -> ... -> /29
+	srv, _ := site(t, func(path string) []string {
+		var n int
+		if path == "/" {
+			return []string{"/0"}
+		}
+		if _, err := fmt.Sscanf(path, "/%d", &n); err != nil || n >= 30 {
+			return nil
+		}
+		return []string{fmt.Sprintf("/%d", n+1)}
+	})
+
+	c := newTestCrawler(t, srv.URL+"/", Options{MaxDepth: 100, Concurrency: 4})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The root, /0../29, and a 404 for /30
+	if len(pages) != 32 {
+		t.Fatalf("got %d pages, want 32", len(pages))
+	}
+
+	c = newTestCrawler(t, srv.URL+"/", Options{MaxDepth: 3})
+	pages, err = c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := crawlURLs(pages)
+	if len(pages) != 4 || !urls[srv.URL+"/2"] || urls[srv.URL+"/3"] {
+		t.Errorf("MaxDepth 3 fetched %v", urls)
+	}
+}
+
+func TestCrawlWide(t *testing.T) {
+	// The root links to 200 leaf pages
+	srv, hits := site(t, func(path string) []string {
+		if path == "/" {
+			var hrefs []string
+			for i := 0; i < 200; i++ {
+				hrefs = append(hrefs, fmt.Sprintf("/leaf/%d", i))
+			}
+			return hrefs
+		}
+		if strings.HasPrefix(path, "/leaf/") {
+			return []string{}
+		}
+		return nil
+	})
+
+	c := newTestCrawler(t, srv.URL+"/", Options{Concurrency: 8})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 201 {
+		t.Errorf("got %d pages, want 201", len(pages))
+	}
+
+	// MaxPages is a hard limit on requests, not just on the result
+	atomic.StoreInt64(hits, 0)
+	c = newTestCrawler(t, srv.URL+"/", Options{MaxPages: 25, Concurrency: 8})
+	pages, err = c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pages) != 25 {
+		t.Errorf("got %d pages, want 25", len(pages))
+	}
+	if n := atomic.LoadInt64(hits); n != 25 {
+		t.Errorf("server saw %d page requests, want 25", n)
+	}
+}
+
+func TestCrawlCyclic(t *testing.T) {
+	// Every page links to every other page and to itself
+	names := []string{"/", "/a", "/b", "/c", "/d"}
+	srv, hits := site(t, func(path string) []string {
+		for _, n := range names {
+			if n == path {
+				return append(names, "/a#top", "/b?")
+			}
+		}
+		return nil
+	})
+
+	c := newTestCrawler(t, srv.URL+"/", Options{Concurrency: 3})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	urls := crawlURLs(pages)
+	for _, n := range names {
+		if !urls[srv.URL+n] {
+			t.Errorf("%s not fetched", n)
+		}
+	}
+	if len(pages) != len(urls) {
+		t.Errorf("a page was fetched twice: %d pages, %d distinct", len(pages), len(urls))
+	}
+	if n := atomic.LoadInt64(hits); int(n) != len(pages) {
+		t.Errorf("server saw %d requests for %d pages", n, len(pages))
+	}
+}
+
+func TestCrawlOnPage(t *testing.T) {
+	// A chain /0 -> /1 -> ... -> /9, fetched one page at a time
+	srv, hits := site(t, func(path string) []string {
+		var n int
+		if path == "/" {
+			return []string{"/0"}
+		}
+		if _, err := fmt.Sscanf(path, "/%d", &n); err != nil || n >= 10 {
+			return nil
+		}
+		return []string{fmt.Sprintf("/%d", n+1)}
+	})
+
+	var seen []*Page
+	var firstAt int64
+	c := newTestCrawler(t, srv.URL+"/", Options{MaxDepth: 100, Concurrency: 1, OnPage: func(p *Page) {
+		if seen == nil {
+			firstAt = atomic.LoadInt64(hits)
+		}
+		seen = append(seen, p)
+	}})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != len(pages) {
+		t.Fatalf("OnPage saw %d pages, Crawl returned %d", len(seen), len(pages))
+	}
+	urls := crawlURLs(seen)
+	for _, p := range pages {
+		if !urls[p.URL] {
+			t.Errorf("OnPage never saw %s", p.URL)
+		}
+	}
+	if total := atomic.LoadInt64(hits); firstAt >= total {
+		t.Errorf("first page reported after %d of %d fetches, want before the crawl ends", firstAt, total)
+	}
+}
+
+func TestCrawlCancel(t *testing.T) {
+	// An endless site: every page links to two new ones
+	srv, _ := site(t, func(path string) []string {
+		var n int
+		if path == "/" {
+			n = 0
+		} else if _, err := fmt.Sscanf(path, "/%d", &n); err != nil {
+			return nil
+		}
+		return []string{fmt.Sprintf("/%d", 2*n+1), fmt.Sprintf("/%d", 2*n+2)}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	c := newTestCrawler(t, srv.URL+"/", Options{MaxDepth: 1000, MaxPages: 100000, Delay: 5 * time.Millisecond})
+
+	done := make(chan struct{})
+	var pages []*Page
+	var err error
+	go func() {
+		pages, err = c.Crawl(ctx, func(n int, url string) {
+			if n == 10 {
+				cancel()
+			}
+		})
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Crawl did not return after cancel")
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if len(pages) != 10 {
+		t.Errorf("got %d pages, want the 10 finished before cancel", len(pages))
+	}
+	for _, p := range pages {
+		if p.Error != "" {
+			t.Errorf("partial result includes failed page %s: %s", p.URL, p.Error)
+		}
+	}
+}
+
+func TestCrawlRobotsCancel(t *testing.T) {
+	// The second host's robots.txt never answers
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			<-r.Context().Done()
+			return
+		}
+		fmt.Fprint(w, "<html><body><p>Other</p></body></html>")
+	}))
+	t.Cleanup(other.Close)
+	srv, _ := site(t, func(path string) []string {
+		if path == "/" {
+			return []string{other.URL + "/page"}
+		}
+		return nil
+	})
+
+	c := newTestCrawler(t, srv.URL+"/", Options{Scope: Scope{Hosts: []string{other.URL}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(200*time.Millisecond, cancel)
+	start := time.Now()
+	if _, err := c.Crawl(ctx, nil); err != nil && !errors.Is(err, context.Canceled) {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Crawl took %v after cancel, want the robots.txt fetch cut short", elapsed)
+	}
+	for _, s := range c.Skipped() {
+		if strings.Contains(s.Reason, "robots.txt") {
+			t.Errorf("%s skipped as %q; a cancelled fetch decides nothing", s.URL, s.Reason)
+		}
+	}
+}
+
+func TestCrawlRetry(t *testing.T) {
+	var calls int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			// Throttled twice, then served
+			if atomic.AddInt64(&calls, 1) <= 2 {
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, `<p>ok</p><a href="/down">down</a>`)
+		case "/down":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newTestCrawler(t, srv.URL+"/", Options{Retries: 2})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byURL := make(map[string]*Page)
+	for _, p := range pages {
+		byURL[p.URL] = p
+	}
+
+	root := byURL[srv.URL+"/"]
+	if root == nil || root.Error != "" || root.Retries != 2 {
+		t.Errorf("root = %+v, want success after 2 retries", root)
+	}
+	down := byURL[srv.URL+"/down"]
+	if down == nil || down.StatusCode != http.StatusServiceUnavailable || down.Retries != 2 {
+		t.Errorf("/down = %+v, want HTTP 503 after 2 retries", down)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+	l := newLimiter(time.Second, 2)
+
+	// Two requests go out at once, then one per second
+	want := []time.Duration{0, 0, time.Second, 2 * time.Second}
+	for i, w := range want {
+		if got := l.reserve("a", now).Sub(now); got != w {
+			t.Errorf("request %d waits %s, want %s", i, got, w)
+		}
+	}
+	// Hosts are limited separately
+	if got := l.reserve("b", now).Sub(now); got != 0 {
+		t.Errorf("other host waits %s", got)
+	}
+
+	// A pause holds requests and leaves no burst afterwards
+	l.pause("b", now.Add(10*time.Second))
+	for i, w := range []time.Duration{10 * time.Second, 11 * time.Second} {
+		if got := l.reserve("b", now).Sub(now); got != w {
+			t.Errorf("request %d after pause waits %s, want %s", i, got, w)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+		ok    bool
+	}{
+		{"120", 2 * time.Minute, true},
+		{"Fri, 02 Jan 2026 03:04:35 GMT", 30 * time.Second, true},
+		{"Fri, 02 Jan 2026 03:00:00 GMT", 0, true},
+		{"", 0, false},
+		{"soon", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseRetryAfter(tt.value, now)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseRetryAfter(%q) = %s, %v; want %s, %v", tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestRobotsAllowed(t *testing.T) {
+	txt := `
+User-agent: *
+Disallow: /
+
+User-agent: SlopSquid
+User-agent: otherbot
+Disallow: /private
+Allow: /private/open
+Disallow: /private/open/secret
+Allow: /docs
+Disallow: /docs
+Disallow: /*.pdf$
+Allow: /files/*.pdf$
+Disallow: /search?
+Disallow: /caf%C3%A9
+
+User-agent: slopsquid
+Disallow: /tmp
+`
+	r := parseRobotsTxt(txt, "SlopSquid/0.4 (+https://example.com)")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/", true}, // our groups replace "*"
+		{"/private", false},
+		{"/private/page", false},          // longest match is Disallow /private
+		{"/private/open/page", true},      // a longer Allow wins
+		{"/private/open/secret/x", false}, // and a still longer Disallow
+		{"/docs/guide", true},             // equal length: Allow wins
+		{"/report.pdf", false},            // $ anchors the end
+		{"/report.pdf?page=2", true},      // so a query escapes it
+		{"/files/report.pdf", true},       // longer Allow pattern
+		{"/search?q=x", false},            // the query is matched
+		{"/search", true},
+		{"/café", false},      // compared percent-encoded
+		{"/tmp/x", false},     // groups for our token merge
+		{"/robots.txt", true}, // always allowed
+	}
+	for _, tt := range tests {
+		u, err := url.Parse("https://example.com" + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := r.allowed(u); got != tt.want {
+			t.Errorf("allowed(%s) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	// Without a group for our token, the "*" groups apply
+	star := parseRobotsTxt(txt, "otherthing/1.0")
+	if u, _ := url.Parse("https://example.com/docs"); star.allowed(u) {
+		t.Errorf("the * group should disallow everything for other agents")
+	}
+}
+
+func TestURLKey(t *testing.T) {
+	same := [][]string{
+		{
+			"https://example.com/docs",
+			"https://example.com/docs/",
+			"https://example.com/docs/index.html",
+			"HTTPS://Example.COM:443/docs/#intro",
+			"https://example.com/docs?utm_source=x&utm_medium=y",
+			"https://example.com/docs/?fbclid=abc",
+		},
+		{
+			"https://example.com/search?q=go&page=2",
+			"https://example.com/search?page=2&q=go",
+			"https://example.com/search/?page=2&gclid=1&q=go",
+		},
+		{
+			"http://example.com",
+			"http://example.com:80/",
+			"http://example.com/default.aspx",
+		},
+	}
+	for _, group := range same {
+		want := urlKey(group[0])
+		for _, u := range group[1:] {
+			if got := urlKey(u); got != want {
+				t.Errorf("urlKey(%q) = %q, want %q", u, got, want)
+			}
+		}
+	}
+
+	different := [][2]string{
+		{"https://example.com/docs", "https://example.com/Docs"},
+		{"https://example.com/search?q=go", "https://example.com/search?q=rust"},
+		{"http://example.com/", "https://example.com/"},
+		{"https://example.com/docs/index.html", "https://example.com/docs/intro.html"},
+	}
+	for _, pair := range different {
+		if urlKey(pair[0]) == urlKey(pair[1]) {
+			t.Errorf("urlKey treats %q and %q as the same page", pair[0], pair[1])
+		}
+	}
+}
+
+func TestExtractCanonical(t *testing.T) {
+	base, _ := url.Parse("https://example.com/blog/post?utm_source=feed")
+	tests := []struct {
+		html, want string
+	}{
+		{`<head><link rel="canonical" href="/blog/post"></head>`, "https://example.com/blog/post"},
+		{`<head><LINK HREF='https://example.com/a#x' REL='Canonical'/></head>`, "https://example.com/a"},
+		{`<head><link rel="alternate canonical" href=other></head>`, "https://example.com/blog/other"},
+		{`<head><link rel="stylesheet" href="/s.css"></head>`, ""},
+		{`<head></head><body><link rel="canonical" href="/late"></body>`, ""},
+	}
+	for _, tt := range tests {
+		if got := extractCanonical(tt.html, base); got != tt.want {
+			t.Errorf("extractCanonical(%q) = %q, want %q", tt.html, got, tt.want)
+		}
+	}
+}
+
+func TestExtractText(t *testing.T) {
+	const prose = "Our gardening guide explains how compost, mulch and patient watering build healthy soil."
+	const extra = "Readers who liked this guide also enjoyed our longer piece on raised beds and crop rotation."
+	tests := []struct {
+		name, html, want string
+	}{
+		{"markup", `<body><nav>Menu</nav><p>` + prose + `</p><script>var x = 1</script></body>`, prose},
+		{"next", `<body><div id="__next"></div><script id="__NEXT_DATA__" type="application/json">` +
+			`{"props":{"pageProps":{"title":"Guide","post":{"body":"<p>` + prose + `</p>"}}},"buildId":"abc"}</script></body>`, prose},
+		{"nuxt 3", `<body><div id="__nuxt"></div><script type="application/json" id="__NUXT_DATA__">[{"data":1},"short label","` + prose + `"]</script></body>`, prose},
+		{"nuxt 2", `<body><script>window.__NUXT__=(function(a){return {data:[{text:"` + prose + `",slug:a}]}}("guide"))</script></body>`, prose},
+		{"json-ld", `<head><script type="application/ld+json">{"@graph":[{"@type":"Article","headline":"Guide","articleBody":"` + prose + `"}]}</script></head><body>Loading</body>`, prose},
+		{"noscript", `<body><noscript><p>` + prose + `</p></noscript><div id="app"></div></body>`, prose},
+		// Server-rendered pages repeat their state; the markup isn't doubled
+		{"rendered", `<body><p>` + prose + `</p><script id="__NEXT_DATA__">{"props":{"body":"` + prose + `"}}</script></body>`, prose},
+		// nor replaced by props the page doesn't show
+		{"ssr next", `<body><div id="__next"><p>` + prose + `</p></div><script id="__NEXT_DATA__" type="application/json">` +
+			`{"props":{"pageProps":{"post":{"body":"` + prose + `","related":["` + extra + `","` + extra + `"]}}}}</script></body>`, prose},
+		{"code", `<body><script id="__NEXT_DATA__">{"props":{"css":".a{color:red}.b{margin:0 0 1px 2px}.c{x:1}.d{y:2}"}}</script></body>`, ""},
+	}
+	for _, tt := range tests {
+		if got := ExtractText(tt.html); got != tt.want {
+			t.Errorf("%s: ExtractText = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCrawlDedup(t *testing.T) {
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		switch r.URL.Path {
+		case "/":
+			atomic.AddInt64(&hits, 1)
+			fmt.Fprint(w, `<p>Home</p>
+				<a href="/docs">a</a> <a href="/docs/">b</a> <a href="/docs/index.html">c</a>
+				<a href="/docs?utm_source=nav">d</a> <a href="/print/docs">e</a>
+				<a href="/copy">f</a> <a href="/old">g</a>`)
+		case "/docs", "/docs/", "/docs/index.html":
+			atomic.AddInt64(&hits, 1)
+			fmt.Fprint(w, `<p>The docs</p>`)
+		case "/print/docs":
+			atomic.AddInt64(&hits, 1)
+			fmt.Fprint(w, `<head><link rel="canonical" href="/docs"></head><p>The docs, printable</p>`)
+		case "/copy":
+			atomic.AddInt64(&hits, 1)
+			fmt.Fprint(w, `<p>The docs</p>`)
+		case "/old":
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+		case "/new":
+			atomic.AddInt64(&hits, 1)
+			fmt.Fprint(w, `<p>Moved here</p>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// One worker, so pages arrive in link order and /docs is kept over /copy
+	c := newTestCrawler(t, srv.URL+"/", Options{Concurrency: 1})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byURL := make(map[string]*Page)
+	for _, p := range pages {
+		byURL[strings.TrimPrefix(p.URL, srv.URL)] = p
+	}
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages %v, want /, /docs and /new", len(pages), crawlURLs(pages))
+	}
+
+	docs := byURL["/docs"]
+	if docs == nil {
+		t.Fatalf("/docs missing from %v", crawlURLs(pages))
+	}
+	aliases := make(map[string]bool)
+	for _, a := range docs.Aliases {
+		aliases[strings.TrimPrefix(a, srv.URL)] = true
+	}
+	if !aliases["/print/docs"] || !aliases["/copy"] || len(aliases) != 2 {
+		t.Errorf("/docs aliases = %v, want /print/docs and /copy", docs.Aliases)
+	}
+
+	moved := byURL["/new"]
+	if moved == nil || len(moved.Aliases) != 1 || moved.Aliases[0] != srv.URL+"/old" {
+		t.Errorf("/new = %+v, want it listed with alias /old", moved)
+	}
+
+	// /, /docs, /print/docs, /copy and /new: the /docs variants are one fetch
+	if n := atomic.LoadInt64(&hits); n != 5 {
+		t.Errorf("server served %d pages, want 5", n)
+	}
+}
+
+func TestScopeCheck(t *testing.T) {
+	root, _ := url.Parse("https://example.com/")
+	sc, err := compileScope(Scope{
+		Hosts:        []string{"*.docs.example.com", "https://blog.example.com/"},
+		IncludePaths: []string{"/guide/", "blog"},
+		ExcludePaths: []string{"/guide/drafts/"},
+		Exclude:      []string{`\?print=1$`},
+	}, root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, reason string
+	}{
+		{"https://example.com/guide/intro", ""},
+		{"https://EXAMPLE.com:8443/guide/intro", ""},
+		{"https://docs.example.com/guide/", ""},
+		{"https://v2.docs.example.com/blog/post", ""},
+		{"https://blog.example.com/blog/", ""},
+		{"https://shop.example.com/guide/", "host shop.example.com is out of scope"},
+		{"https://notdocs.example.com/guide/", "host notdocs.example.com is out of scope"},
+		{"https://example.com/about", "outside the included paths"},
+		{"https://example.com/guide/drafts/x", "excluded path /guide/drafts/"},
+		{"https://example.com/guide/intro?print=1", `matches exclude pattern \?print=1$`},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := sc.check(u); got != tt.reason {
+			t.Errorf("check(%s) = %q, want %q", tt.url, got, tt.reason)
+		}
+	}
+
+	sc, _ = compileScope(Scope{Include: []string{`/v\d+/`}}, root)
+	u, _ := url.Parse("https://example.com/latest/")
+	if got := sc.check(u); got != "matches no include pattern" {
+		t.Errorf("include pattern: got %q", got)
+	}
+	if _, err := compileScope(Scope{Exclude: []string{"("}}, root); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+
+	// Default ports are dropped from the root and from listed hosts
+	root, _ = url.Parse("https://Example.com:443/")
+	sc, _ = compileScope(Scope{Hosts: []string{"https://api.example.com:443/", "cdn.example.com:80"}}, root)
+	for _, raw := range []string{
+		"https://example.com/page",
+		"https://example.com:443/page",
+		"https://api.example.com/v1",
+		"http://cdn.example.com/app.js",
+	} {
+		u, _ := url.Parse(raw)
+		if got := sc.check(u); got != "" {
+			t.Errorf("default ports: check(%s) = %q, want in scope", raw, got)
+		}
+	}
+}
+
+func TestCrawlScope(t *testing.T) {
+	leaf := func(path string) []string {
+		if path == "/blog/" {
+			return []string{}
+		}
+		return nil
+	}
+	other, _ := site(t, leaf)
+	third, _ := site(t, leaf)
+	srv, _ := site(t, func(path string) []string {
+		switch path {
+		case "/", "/blog/":
+			return []string{"/blog/", "/blog/post", "/blog/post?print=1", "/blog/drafts/wip", "/about",
+				other.URL + "/blog/", third.URL + "/blog/"}
+		case "/blog/post", "/blog/drafts/wip", "/about":
+			return []string{}
+		}
+		return nil
+	})
+
+	// A root outside the included paths is followed for its links but not
+	// reported
+	c := newTestCrawler(t, srv.URL+"/", Options{Scope: Scope{IncludePaths: []string{"/blog/"}}})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if urls := crawlURLs(pages); urls[srv.URL+"/"] || urls[srv.URL+"/about"] || !urls[srv.URL+"/blog/"] || !urls[srv.URL+"/blog/drafts/wip"] {
+		t.Errorf("crawl from an out-of-scope root = %v, want the /blog/ pages only", urls)
+	}
+	var rootSkip string
+	for _, s := range c.Skipped() {
+		if s.URL == srv.URL+"/" {
+			rootSkip = s.Reason
+		}
+	}
+	if rootSkip != "outside the included paths; followed for links only" {
+		t.Errorf("root skip reason = %q", rootSkip)
+	}
+
+	c = newTestCrawler(t, srv.URL+"/blog/", Options{Scope: Scope{
+		Hosts:        []string{strings.TrimPrefix(other.URL, "http://")},
+		IncludePaths: []string{"/blog/"},
+		ExcludePaths: []string{"/blog/drafts/"},
+		Exclude:      []string{`print=1$`},
+	}})
+	pages, err = c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urls := crawlURLs(pages)
+	for _, want := range []string{srv.URL + "/blog/", srv.URL + "/blog/post", other.URL + "/blog/"} {
+		if !urls[want] {
+			t.Errorf("%s not crawled", want)
+		}
+	}
+	if len(pages) != 3 {
+		t.Errorf("got %d pages %v, want 3", len(pages), urls)
+	}
+
+	reasons := make(map[string]string)
+	for _, s := range c.Skipped() {
+		reasons[s.URL] = s.Reason
+	}
+	want := map[string]string{
+		srv.URL + "/blog/drafts/wip":   "excluded path /blog/drafts/",
+		srv.URL + "/blog/post?print=1": "matches exclude pattern print=1$",
+		srv.URL + "/about":             "outside the included paths",
+		third.URL + "/blog/":           "host " + strings.TrimPrefix(third.URL, "http://") + " is out of scope",
+	}
+	for u, reason := range want {
+		if reasons[u] != reason {
+			t.Errorf("skip reason for %s = %q, want %q", u, reasons[u], reason)
+		}
+	}
+	if len(reasons) != len(want) {
+		t.Errorf("skipped %v, want %v", reasons, want)
+	}
+}
+
+func TestCrawlResume(t *testing.T) {
+	// The root links to 30 leaves; each leaf has an ETag. Requests are
+	// counted only for the current crawl, whose number is in the user agent,
+	// since the interrupted one can leave requests behind.
+	var fetched, notModified, phase int64
+	count := func(r *http.Request, n *int64) {
+		if r.UserAgent() == fmt.Sprintf("test/%d", atomic.LoadInt64(&phase)) {
+			atomic.AddInt64(n, 1)
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaf, isLeaf := strings.CutPrefix(r.URL.Path, "/leaf/")
+		n, err := strconv.Atoi(leaf)
+		switch {
+		case r.URL.Path == "/":
+			count(r, &fetched)
+			w.Header().Set("Content-Type", "text/html")
+			for i := 0; i < 30; i++ {
+				fmt.Fprintf(w, `<a href="/leaf/%d">%d</a>`, i, i)
+			}
+		case isLeaf && err == nil:
+			etag := fmt.Sprintf(`"v%d"`, n)
+			if r.Header.Get("If-None-Match") == etag {
+				count(r, &notModified)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			count(r, &fetched)
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("ETag", etag)
+			fmt.Fprintf(w, "<p>Leaf number %d</p>", n)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	dir := t.TempDir()
+	crawl := func(ctx context.Context, stop int, cancel func()) []*Page {
+		t.Helper()
+		state, err := OpenState(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ua := fmt.Sprintf("test/%d", atomic.AddInt64(&phase, 1))
+		c := newTestCrawler(t, srv.URL+"/", Options{Concurrency: 2, State: state, UserAgent: ua})
+		pages, _ := c.Crawl(ctx, func(n int, url string) {
+			if n == stop {
+				cancel()
+			}
+		})
+		if err := state.Err(); err != nil {
+			t.Fatal(err)
+		}
+		return pages
+	}
+
+	// Interrupted after 10 pages
+	ctx, cancel := context.WithCancel(context.Background())
+	first := crawl(ctx, 10, cancel)
+	if len(first) != 10 {
+		t.Fatalf("first crawl kept %d pages, want 10", len(first))
+	}
+	state, _ := OpenState(dir, false)
+	if done, queued := state.Resuming(); done != 10 || queued != 21 {
+		t.Errorf("Resuming() = %d, %d; want 10 fetched, 21 queued", done, queued)
+	}
+
+	// The resumed crawl fetches only what's left and reports everything
+	atomic.StoreInt64(&fetched, 0)
+	second := crawl(context.Background(), -1, nil)
+	if len(second) != 31 {
+		t.Errorf("resumed crawl has %d pages, want 31", len(second))
+	}
+	if n := atomic.LoadInt64(&fetched); n != 21 {
+		t.Errorf("resumed crawl fetched %d pages, want 21", n)
+	}
+
+	// A new crawl after a finished one is conditional
+	atomic.StoreInt64(&fetched, 0)
+	third := crawl(context.Background(), -1, nil)
+	if len(third) != 31 {
+		t.Errorf("re-crawl has %d pages, want 31", len(third))
+	}
+	if n := atomic.LoadInt64(&notModified); n != 30 {
+		t.Errorf("re-crawl got %d 304s, want 30", n)
+	}
+	for _, p := range third {
+		if strings.Contains(p.URL, "/leaf/") && (!p.NotModified || !strings.Contains(p.Text, "Leaf number")) {
+			t.Errorf("%s: NotModified %v, text %q", p.URL, p.NotModified, p.Text)
+		}
+	}
+}
+
+func TestCrawlFormats(t *testing.T) {
+	docs := map[string]struct{ contentType, body string }{
+		"/": {"text/html", `<p>Home</p><a href="/guide.md">guide</a> <a href="/feed.rss">feed</a>
+			<a href="/page.xhtml">page</a> <a href="/notes.txt">notes</a> <a href="/logo">logo</a>
+			<a href="/feed.xml">feed</a> <a href="/data.xml">data</a>`},
+		"/guide.md":   {"text/plain; charset=utf-8", "# Guide\n\nRead **this** and [more](/more.md).\n"},
+		"/more.md":    {"text/markdown", "More *words*."},
+		"/feed.rss":   {"application/rss+xml", `<rss><channel><title>Blog</title><item><title>Post</title><description>&lt;p&gt;Item body&lt;/p&gt;</description></item></channel></rss>`},
+		"/page.xhtml": {"application/xhtml+xml", `<html xmlns="http://www.w3.org/1999/xhtml"><body><p>Strict page</p></body></html>`},
+		"/notes.txt":  {"text/plain", "Use <b> for bold."},
+		"/logo":       {"image/png", "\x89PNG"},
+		"/feed.xml":   {"application/xml", `<?xml version="1.0"?><feed xmlns="http://www.w3.org/2005/Atom"><entry><title>Entry</title><summary>Atom body</summary></entry></feed>`},
+		"/data.xml":   {"text/xml", `<?xml version="1.0"?><urlset><url><loc>https://example.com/</loc></url></urlset>`},
+	}
+	var accept atomic.Value
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		accept.Store(r.Header.Get("Accept"))
+		w.Header().Set("Content-Type", doc.contentType)
+		fmt.Fprint(w, doc.body)
+	}))
+	t.Cleanup(srv.Close)
+
+	c := newTestCrawler(t, srv.URL+"/", Options{})
+	pages, err := c.Crawl(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := accept.Load().(string); !strings.Contains(a, "text/markdown") || !strings.Contains(a, "application/atom+xml") {
+		t.Errorf("Accept = %q, want Markdown and feeds", a)
+	}
+
+	want := map[string]string{
+		"/guide.md":   "Guide\nRead this and more.",
+		"/more.md":    "More words.",
+		"/feed.rss":   "Post\nItem body",
+		"/page.xhtml": "Strict page",
+		"/notes.txt":  "Use <b> for bold.",
+		"/feed.xml":   "Entry\nAtom body",
+	}
+	got := make(map[string]*Page)
+	for _, p := range pages {
+		got[strings.TrimPrefix(p.URL, srv.URL)] = p
+	}
+	for path, text := range want {
+		p := got[path]
+		switch {
+		case p == nil:
+			t.Errorf("%s not crawled", path)
+		case p.Error != "":
+			t.Errorf("%s: %s", path, p.Error)
+		case p.Text != text:
+			t.Errorf("%s: text %q, want %q", path, p.Text, text)
+		}
+	}
+	for _, path := range []string{"/logo", "/data.xml"} {
+		if p := got[path]; p == nil || p.Error != "not text content" {
+			t.Errorf("%s = %+v, want not text content", path, p)
+		}
+	}
+}
+
+func writeSitemap(t *testing.T, path, root string, locs ...string) {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, `<?xml version="1.0"?><%s>`, root)
+	item := "url"
+	if root == "sitemapindex" {
+		item = "sitemap"
+	}
+	for _, loc := range locs {
+		fmt.Fprintf(&b, "<%s><loc>%s</loc></%s>", item, loc, item)
+	}
+	fmt.Fprintf(&b, "</%s>", root)
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadSitemapsLocal(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "parts"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	writeSitemap(t, filepath.Join(dir, "index.xml"), "sitemapindex", "parts/a.xml", "file://"+filepath.Join(dir, "b.xml"))
+	writeSitemap(t, filepath.Join(dir, "parts", "a.xml"), "urlset", "https://example.com/a")
+	writeSitemap(t, filepath.Join(dir, "b.xml"), "urlset", "https://example.com/b")
+
+	c := newTestCrawler(t, "https://example.com/", Options{Sitemaps: []string{filepath.Join(dir, "index.xml")}})
+	var got []string
+	for _, e := range c.loadSitemaps(context.Background(), c.sitemapSources()) {
+		got = append(got, e.URL)
+	}
+	if strings.Join(got, " ") != "https://example.com/a https://example.com/b" {
+		t.Errorf("pages = %q, want a and b from the local index's directory", got)
+	}
+}
+
+func TestLoadSitemapsRemoteRefs(t *testing.T) {
+	dir := t.TempDir()
+	local := filepath.Join(dir, "local.xml")
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/index.xml":
+			fmt.Fprintf(w, `<sitemapindex><sitemap><loc>pages.xml</loc></sitemap>`+
+				`<sitemap><loc>file://%s</loc></sitemap><sitemap><loc>%s</loc></sitemap></sitemapindex>`, local, local)
+		case "/pages.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>%s/remote</loc></url></urlset>`, srv.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	writeSitemap(t, local, "urlset", srv.URL+"/local")
+
+	// A file that robots.txt declares, rather than Options.Sitemaps, isn't
+	// opened either
+	c := newTestCrawler(t, srv.URL+"/", Options{Sitemaps: []string{srv.URL + "/index.xml"}})
+	var got []string
+	for _, e := range c.loadSitemaps(context.Background(), []string{srv.URL + "/index.xml", local}) {
+		got = append(got, e.URL)
+	}
+	if len(got) != 1 || got[0] != srv.URL+"/remote" {
+		t.Errorf("pages = %q, want only %s/remote", got, srv.URL)
+	}
+}
+
+func TestCrawlSince(t *testing.T) {
+	var aFetched int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/sitemap.xml":
+			fmt.Fprintf(w, `<urlset><url><loc>http://%s/a</loc><lastmod>2026-01-01</lastmod></url>`+
+				`<url><loc>http://%s/b</loc><lastmod>2026-03-01</lastmod></url></urlset>`, r.Host, r.Host)
+		case "/", "/b":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprintf(w, "<p>Page %s</p>", r.URL.Path)
+		case "/a":
+			atomic.AddInt64(&aFetched, 1)
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<p>Page a</p>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	since := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	crawl := func(dir string, since time.Time) (*Crawler, map[string]*Page) {
+		t.Helper()
+		state, err := OpenState(dir, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := newTestCrawler(t, srv.URL+"/", Options{State: state, Since: since})
+		pages, err := c.Crawl(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		byURL := make(map[string]*Page)
+		for _, p := range pages {
+			byURL[p.URL] = p
+		}
+		return c, byURL
+	}
+
+	// After a full crawl, an unchanged page is reported from the saved state
+	dir := t.TempDir()
+	crawl(dir, time.Time{})
+	for run := 0; run < 2; run++ {
+		c, pages := crawl(dir, since)
+		a := pages[srv.URL+"/a"]
+		if a == nil || !a.NotModified || a.Text != "Page a" {
+			t.Fatalf("run %d: unchanged page = %+v, want the saved copy", run, a)
+		}
+		if pages[srv.URL+"/b"] == nil || c.Unchanged() != 0 {
+			t.Errorf("run %d: pages %v, %d unchanged without a copy", run, pages, c.Unchanged())
+		}
+	}
+	if n := atomic.LoadInt64(&aFetched); n != 1 {
+		t.Errorf("unchanged page fetched %d times, want 1", n)
+	}
+
+	// Without saved state it is listed as skipped
+	c, pages := crawl(t.TempDir(), since)
+	if pages[srv.URL+"/a"] != nil || c.Unchanged() != 1 {
+		t.Errorf("without state: pages %v, %d unchanged", pages, c.Unchanged())
+	}
+	skips := c.Skipped()
+	if len(skips) != 1 || skips[0].URL != srv.URL+"/a" || skips[0].Reason != "unchanged since 2026-02-01 00:00" {
+		t.Errorf("skipped = %v", skips)
+	}
+}
@@ -0,0 +1,95 @@
+package crawler
+
+import (
+	"crypto/sha256"
+)
+
+// deduper folds pages that are the same content under another URL into one
+// page, listing the other URLs as aliases. A page is a duplicate when its
+// canonical URL (rel=canonical, or where a redirect ended) belongs to a page
+// already kept, or when its text is identical to one. It runs on the
+// coordinator goroutine only.
+type deduper struct {
+	byKey  map[string]*Page
+	byHash map[[32]byte]*Page
+	// waiting holds pages whose canonical URL is queued but not fetched yet
+	waiting map[string][]*Page
+}
+
+func newDeduper() *deduper {
+	return &deduper{
+		byKey:   make(map[string]*Page),
+		byHash:  make(map[[32]byte]*Page),
+		waiting: make(map[string][]*Page),
+	}
+}
+
+// add takes a fetched page and returns the pages that are now final: none
+// when p is a duplicate or waits for its canonical page, usually p itself,
+// and any pages released because the page they waited for failed
+func (d *deduper) add(c *Crawler, p *Page) []*Page {
+	if p.Canonical != "" {
+		key := urlKey(p.Canonical)
+		if first := d.byKey[key]; first != nil {
+			first.Aliases = append(first.Aliases, p.URL)
+			return nil
+		}
+		if !c.tryVisit(p.Canonical) {
+			// Someone else fetches the canonical URL
+			d.waiting[key] = append(d.waiting[key], p)
+			return nil
+		}
+		// The canonical URL hasn't been seen: report the page under it
+		p.Aliases = append(p.Aliases, p.URL)
+		p.URL = p.Canonical
+		p.Canonical = ""
+	}
+
+	key := urlKey(p.URL)
+	waiting := d.waiting[key]
+	delete(d.waiting, key)
+
+	if p.Error != "" {
+		// The pages waiting for p stand on their own after all
+		out := []*Page{p}
+		for _, w := range waiting {
+			out = append(out, d.keep(w)...)
+		}
+		return out
+	}
+
+	for _, w := range waiting {
+		p.Aliases = append(p.Aliases, w.URL)
+	}
+	return d.keep(p)
+}
+
+// keep records p unless its text duplicates a kept page
+func (d *deduper) keep(p *Page) []*Page {
+	if p.Error == "" && p.Text != "" {
+		h := sha256.Sum256([]byte(p.Text))
+		if first := d.byHash[h]; first != nil {
+			first.Aliases = append(first.Aliases, p.URL)
+			first.Aliases = append(first.Aliases, p.Aliases...)
+			// Pages naming p as canonical now find first instead
+			d.byKey[urlKey(p.URL)] = first
+			return nil
+		}
+		d.byHash[h] = p
+	}
+	d.byKey[urlKey(p.URL)] = p
+	return []*Page{p}
+}
+
+// flush returns the pages still waiting for a canonical page that was never
+// fetched, for example because MaxPages ran out
+func (d *deduper) flush() []*Page {
+	var out []*Page
+	for key, pages := range d.waiting {
+		delete(d.waiting, key)
+		for _, p := range pages {
+			out = append(out, d.keep(p)...)
+		}
+	}
+	return out
+}
@@ -0,0 +1,230 @@
+package crawler
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Extractor pulls a page's prose from somewhere other than its visible
+// markup, such as the state a JavaScript framework embeds in the page.
+// Extract returns "" when the page holds nothing it recognizes.
+type Extractor struct {
+	Name    string
+	Extract func(html string) string
+}
+
+// extractors are tried in order by ExtractText
+var extractors = []Extractor{
+	{Name: "next", Extract: extractNextData},
+	{Name: "nuxt", Extract: extractNuxt},
+	{Name: "json-ld", Extract: extractJSONLD},
+	{Name: "noscript", Extract: extractNoscript},
+}
+
+// RegisterExtractor adds an extractor for ExtractText to try after the
+// built-in ones. Call it before crawling starts, from an init function.
+func RegisterExtractor(e Extractor) {
+	extractors = append(extractors, e)
+}
+
+// MinWords is the fewest words a page needs to be scored. Pages whose markup
+// shows fewer are read from their embedded state instead.
+const MinWords = 10
+
+// minProseWords is how long a string in embedded state must be to count as
+// prose rather than a label, slug or setting
+const minProseWords = 8
+
+var (
+	scriptRegex     = regexp.MustCompile(`(?is)<script\b([^>]*)>(.*?)</script>`)
+	scriptAttrRegex = regexp.MustCompile(`(?i)\b(id|type)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	noscriptRegex   = regexp.MustCompile(`(?is)<noscript\b[^>]*>(.*?)</noscript>`)
+	nuxtStateRegex  = regexp.MustCompile(`window\.__NUXT__\s*=`)
+	jsStringRegex   = regexp.MustCompile(`"(?:[^"\\\n]|\\.)*"`)
+	htmlTagRegex    = regexp.MustCompile(`<[a-zA-Z/][^>]*>`)
+)
+
+// script is one <script> element of a page
+type script struct {
+	id, typ, body string
+}
+
+func scripts(html string) []script {
+	var out []script
+	for _, m := range scriptRegex.FindAllStringSubmatch(html, -1) {
+		s := script{body: strings.TrimSpace(m[2])}
+		for _, a := range scriptAttrRegex.FindAllStringSubmatch(m[1], -1) {
+			value := a[2] + a[3] + a[4]
+			if strings.EqualFold(a[1], "id") {
+				s.id = value
+			} else {
+				s.typ = strings.ToLower(strings.TrimSpace(value))
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+// extractNextData reads the page props Next.js embeds in __NEXT_DATA__
+func extractNextData(html string) string {
+	for _, s := range scripts(html) {
+		if s.id != "__NEXT_DATA__" {
+			continue
+		}
+		var data struct {
+			Props json.RawMessage `json:"props"`
+		}
+		if json.Unmarshal([]byte(s.body), &data) != nil {
+			return ""
+		}
+		return joinProse(jsonStrings(data.Props, ""))
+	}
+	return ""
+}
+
+// extractNuxt reads Nuxt's state: the __NUXT_DATA__ JSON of Nuxt 3, or the
+// string literals of the window.__NUXT__ script of Nuxt 2
+func extractNuxt(html string) string {
+	for _, s := range scripts(html) {
+		if s.id == "__NUXT_DATA__" {
+			return joinProse(jsonStrings([]byte(s.body), ""))
+		}
+		if nuxtStateRegex.MatchString(s.body) {
+			var strs []string
+			for _, lit := range jsStringRegex.FindAllString(s.body, -1) {
+				var v string
+				if json.Unmarshal([]byte(lit), &v) == nil {
+					strs = append(strs, v)
+				}
+			}
+			return joinProse(strs)
+		}
+	}
+	return ""
+}
+
+// extractJSONLD reads the articleBody of a page's JSON-LD metadata
+func extractJSONLD(html string) string {
+	var parts []string
+	for _, s := range scripts(html) {
+		if s.typ != "application/ld+json" {
+			continue
+		}
+		for _, body := range jsonStrings([]byte(s.body), "articleBody") {
+			if body = stripMarkup(body); body != "" {
+				parts = append(parts, body)
+			}
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// extractNoscript reads the fallback a page shows browsers without
+// JavaScript
+func extractNoscript(html string) string {
+	var parts []string
+	for _, m := range noscriptRegex.FindAllStringSubmatch(html, -1) {
+		if text := visibleText(m[1]); text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// jsonStrings returns the string values in data, in document order. With a
+// key, only the values of that key, or of arrays under it, are returned.
+func jsonStrings(data []byte, key string) []string {
+	type frame struct {
+		object bool
+		key    string // in an object, the key of the current value
+	}
+	var (
+		out       []string
+		stack     []frame
+		expectKey bool
+	)
+	current := func() string {
+		if len(stack) == 0 {
+			return ""
+		}
+		return stack[len(stack)-1].key
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return out
+		}
+		switch v := tok.(type) {
+		case json.Delim:
+			switch v {
+			case '{':
+				stack = append(stack, frame{object: true})
+				expectKey = true
+				continue
+			case '[':
+				// Array elements count as values of the array's key
+				stack = append(stack, frame{key: current()})
+				expectKey = false
+				continue
+			default:
+				stack = stack[:len(stack)-1]
+			}
+		case string:
+			if expectKey {
+				stack[len(stack)-1].key = v
+				expectKey = false
+				continue
+			}
+			if key == "" || current() == key {
+				out = append(out, v)
+			}
+		}
+		// A value is done; inside an object a key comes next
+		expectKey = len(stack) > 0 && stack[len(stack)-1].object
+	}
+}
+
+// joinProse keeps the strings that read as prose, once each
+func joinProse(strs []string) string {
+	seen := make(map[string]bool)
+	var parts []string
+	for _, s := range strs {
+		s = stripMarkup(s)
+		if seen[s] || !isProse(s) {
+			continue
+		}
+		seen[s] = true
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// stripMarkup returns the text of s, which may be an HTML fragment
+func stripMarkup(s string) string {
+	if htmlTagRegex.MatchString(s) {
+		return visibleText(s)
+	}
+	return strings.TrimSpace(s)
+}
+
+// isProse reports whether s is a run of words rather than code, data or a
+// short label
+func isProse(s string) bool {
+	if len(strings.Fields(s)) < minProseWords {
+		return false
+	}
+	letters := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsSpace(r) {
+			letters++
+		}
+	}
+	return letters*10 >= utf8.RuneCountInString(s)*7
+}
@@ -0,0 +1,154 @@
+package crawler
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backoff bounds for retrying transient failures
+const (
+	retryBase = 500 * time.Millisecond
+	retryCap  = 30 * time.Second
+	// A Retry-After longer than this isn't waited out; the page fails
+	retryAfterMax = 2 * time.Minute
+)
+
+// limiter spaces requests per host with a token bucket, shared by all
+// workers, so adding workers doesn't raise the request rate
+type limiter struct {
+	interval time.Duration
+	burst    int
+
+	mu    sync.Mutex
+	hosts map[string]*bucket
+}
+
+// bucket is one host's token bucket, kept as the time the bucket next
+// refills completely (GCRA), plus any pause the server asked for
+type bucket struct {
+	full     time.Time
+	paused   time.Time
+	interval time.Duration // this host's Crawl-delay, when longer than the default
+}
+
+func newLimiter(interval time.Duration, burst int) *limiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &limiter{interval: interval, burst: burst, hosts: make(map[string]*bucket)}
+}
+
+func (l *limiter) bucket(host string) *bucket {
+	b := l.hosts[host]
+	if b == nil {
+		b = &bucket{interval: l.interval}
+		l.hosts[host] = b
+	}
+	return b
+}
+
+// slowDown raises the interval for host, for a robots.txt Crawl-delay
+func (l *limiter) slowDown(host string, interval time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if b := l.bucket(host); interval > b.interval {
+		b.interval = interval
+	}
+}
+
+// reserve takes a token for host and returns when it may be used
+func (l *limiter) reserve(host string, now time.Time) time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host)
+
+	if b.full.Before(now) {
+		b.full = now
+	}
+	at := b.full.Add(-time.Duration(l.burst-1) * b.interval)
+	if at.Before(now) {
+		at = now
+	}
+	if at.Before(b.paused) {
+		at = b.paused
+	}
+	b.full = b.full.Add(b.interval)
+	return at
+}
+
+// wait blocks until a request to host is allowed
+func (l *limiter) wait(ctx context.Context, host string) error {
+	now := time.Now()
+	return sleep(ctx, l.reserve(host, now).Sub(now))
+}
+
+// pause holds every request to host until the given time and drains the
+// bucket, so requests resume one interval apart rather than in a burst
+func (l *limiter) pause(host string, until time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b := l.bucket(host)
+
+	if until.After(b.paused) {
+		b.paused = until
+	}
+	if drained := until.Add(time.Duration(l.burst-1) * b.interval); drained.After(b.full) {
+		b.full = drained
+	}
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// backoff returns the wait before retry n (0-based): exponential with full
+// jitter, so workers that failed together don't retry together
+func backoff(n int) time.Duration {
+	d := retryCap
+	if n < 16 {
+		d = min(retryBase<<n, retryCap)
+	}
+	return time.Duration(rand.Int64N(int64(d))) + 1
+}
+
+// transientStatus reports whether a response status is worth retrying
+func transientStatus(code int) bool {
+	switch code {
+	case http.StatusRequestTimeout, http.StatusTooManyRequests,
+		http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(secs)*time.Second, 0), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
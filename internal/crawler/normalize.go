@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// trackingParams are query parameters that identify a campaign or click
+// rather than content; keys ending in "*" match as prefixes
+var trackingParams = []string{
+	"utm_*", "gclid", "gclsrc", "dclid", "fbclid", "msclkid", "yclid",
+	"mc_cid", "mc_eid", "_ga", "_gl", "igshid", "_hsenc", "_hsmi",
+}
+
+// indexPages are file names servers use for a directory's default document
+var indexPages = map[string]bool{
+	"index.html": true, "index.htm": true, "index.php": true, "index.shtml": true,
+	"default.htm": true, "default.html": true, "default.aspx": true,
+}
+
+var canonicalRegex = regexp.MustCompile(`(?is)<link\b[^>]*>`)
+var attrRegex = regexp.MustCompile(`(?i)\b(rel|href)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+func isTrackingParam(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range trackingParams {
+		if prefix, ok := strings.CutSuffix(p, "*"); ok {
+			if strings.HasPrefix(key, prefix) {
+				return true
+			}
+		} else if key == p {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeURL rewrites u into the form the crawler fetches: lowercase scheme
+// and host, no default port, no fragment, and a sorted query without
+// tracking parameters. Every change keeps the URL pointing at the same
+// resource.
+func normalizeURL(u *url.URL) *url.URL {
+	n := *u
+	n.Scheme = strings.ToLower(n.Scheme)
+	n.Host = strings.ToLower(n.Host)
+	if host, port, err := net.SplitHostPort(n.Host); err == nil {
+		if (n.Scheme == "http" && port == "80") || (n.Scheme == "https" && port == "443") {
+			n.Host = host
+		}
+	}
+	if n.Path == "" {
+		n.Path = "/"
+		n.RawPath = ""
+	}
+	n.Fragment = ""
+	n.RawFragment = ""
+
+	if n.RawQuery != "" {
+		q := n.Query()
+		for key := range q {
+			if isTrackingParam(key) {
+				delete(q, key)
+			}
+		}
+		// Encode sorts by key; values keep their order
+		n.RawQuery = q.Encode()
+	}
+	n.ForceQuery = false
+	return &n
+}
+
+// urlKey identifies the page behind a URL for deduplication. Beyond
+// normalizeURL it drops a default index file name and the trailing slash,
+// which servers nearly always treat as the same page but which can't be
+// removed from the URL that is fetched.
+func urlKey(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	n := normalizeURL(u)
+
+	dir, file := n.Path[:strings.LastIndex(n.Path, "/")+1], n.Path[strings.LastIndex(n.Path, "/")+1:]
+	if indexPages[strings.ToLower(file)] {
+		n.Path = dir
+	}
+	if len(n.Path) > 1 {
+		n.Path = strings.TrimSuffix(n.Path, "/")
+	}
+	n.RawPath = ""
+	return n.String()
+}
+
+// extractCanonical returns the href of the page's <link rel="canonical">,
+// resolved against pageURL, or "" when there is none
+func extractCanonical(html string, pageURL *url.URL) string {
+	// Only the head can declare it; stop early on large pages
+	if end := strings.Index(strings.ToLower(html), "</head>"); end >= 0 {
+		html = html[:end]
+	}
+	for _, tag := range canonicalRegex.FindAllString(html, -1) {
+		var rel, href string
+		for _, m := range attrRegex.FindAllStringSubmatch(tag, -1) {
+			value := m[2] + m[3] + m[4]
+			if strings.EqualFold(m[1], "rel") {
+				rel = value
+			} else {
+				href = value
+			}
+		}
+		if !hasToken(rel, "canonical") || strings.TrimSpace(href) == "" {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			return ""
+		}
+		return normalizeURL(pageURL.ResolveReference(ref)).String()
+	}
+	return ""
+}
+
+// hasToken reports whether a space-separated attribute value contains token
+func hasToken(value, token string) bool {
+	for _, f := range strings.Fields(value) {
+		if strings.EqualFold(f, token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,205 @@
+package crawler
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// robots holds the parts of a robots.txt (RFC 9309) that apply to us: the
+// allow/disallow rules of the groups matching our product token (or the "*"
+// groups when none do), their Crawl-delay, and every Sitemap line
+type robots struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string
+	disallowed bool // robots.txt was unreachable (5xx), so nothing may be crawled
+}
+
+// robotsRule is one Allow or Disallow line, compiled once
+type robotsRule struct {
+	allow   bool
+	pattern string         // as written, percent-encoded
+	re      *regexp.Regexp // nil when the pattern is a plain prefix
+}
+
+// robotsGroup collects the lines following one or more user-agent lines
+type robotsGroup struct {
+	agents     []string
+	rules      []robotsRule
+	crawlDelay time.Duration
+	hasDelay   bool
+}
+
+// productToken is the name part of a user agent string, e.g. "slopsquid"
+// for "SlopSquid/0.3 (+https://...)"
+func productToken(userAgent string) string {
+	token := userAgent
+	if i := strings.IndexAny(token, "/ "); i >= 0 {
+		token = token[:i]
+	}
+	return strings.ToLower(token)
+}
+
+// parseRobotsTxt parses content per RFC 9309. Consecutive user-agent lines
+// share one group; every group naming our product token is merged, and the
+// "*" groups are used only when none do.
+func parseRobotsTxt(content string, userAgent string) *robots {
+	content = strings.TrimPrefix(content, "\ufeff")
+	token := productToken(userAgent)
+
+	r := &robots{}
+	var groups []*robotsGroup
+	var cur *robotsGroup
+	inAgents := false // the previous line was a user-agent line
+
+	for _, line := range strings.Split(content, "\n") {
+		if idx := strings.Index(line, "#"); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				cur = &robotsGroup{}
+				groups = append(groups, cur)
+			}
+			inAgents = true
+			cur.agents = append(cur.agents, strings.ToLower(value))
+			continue
+		case "sitemap":
+			// Sitemap lines are global and don't end a group
+			if value != "" {
+				r.sitemaps = append(r.sitemaps, value)
+			}
+			continue
+		}
+		inAgents = false
+		if cur == nil {
+			// Rules before any user-agent line belong to no group
+			continue
+		}
+
+		switch key {
+		case "allow", "disallow":
+			if value == "" {
+				// An empty Disallow allows everything, which is the default
+				continue
+			}
+			cur.rules = append(cur.rules, newRobotsRule(key == "allow", value))
+		case "crawl-delay":
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs >= 0 {
+				cur.crawlDelay = time.Duration(secs * float64(time.Second))
+				cur.hasDelay = true
+			}
+		}
+	}
+
+	var ours, star []*robotsGroup
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				star = append(star, g)
+				break
+			}
+			if productToken(a) == token {
+				ours = append(ours, g)
+				break
+			}
+		}
+	}
+	if len(ours) == 0 {
+		ours = star
+	}
+	for _, g := range ours {
+		r.rules = append(r.rules, g.rules...)
+		if g.hasDelay && g.crawlDelay > r.crawlDelay {
+			r.crawlDelay = g.crawlDelay
+		}
+	}
+	return r
+}
+
+func newRobotsRule(allow bool, pattern string) robotsRule {
+	pattern = normalizeRobotsPath(pattern)
+	rule := robotsRule{allow: allow, pattern: pattern}
+	if !strings.ContainsAny(pattern, "*$") {
+		return rule
+	}
+
+	anchored := strings.HasSuffix(pattern, "$")
+	body := strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(body), `\*`, `.*`)
+	if anchored {
+		expr += "$"
+	}
+	rule.re = regexp.MustCompile(expr)
+	return rule
+}
+
+// normalizeRobotsPath percent-encodes characters outside printable ASCII so
+// rules and URLs compare octet for octet
+func normalizeRobotsPath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c <= 0x20 || c >= 0x7f {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// matches reports whether the rule covers path
+func (rule robotsRule) matches(path string) bool {
+	if rule.re != nil {
+		return rule.re.MatchString(path)
+	}
+	return strings.HasPrefix(path, rule.pattern)
+}
+
+// allowed applies the most specific (longest) matching rule; when an Allow
+// and a Disallow are equally long, Allow wins
+func (r *robots) allowed(u *url.URL) bool {
+	if r == nil {
+		return true
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path == "/robots.txt" {
+		return true
+	}
+	if r.disallowed {
+		return false
+	}
+	if u.RawQuery != "" {
+		path += "?" + u.RawQuery
+	}
+
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !rule.matches(path) {
+			continue
+		}
+		n := len(rule.pattern)
+		if n > best || (n == best && rule.allow) {
+			best = n
+			allow = rule.allow
+		}
+	}
+	return allow
+}
@@ -0,0 +1,173 @@
+package crawler
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Scope limits which URLs a crawl follows. The zero Scope keeps the crawl on
+// the root URL's host.
+type Scope struct {
+	// Hosts are crawled besides the root's. "*.example.com" matches every
+	// subdomain of example.com and example.com itself.
+	Hosts []string
+	// IncludePaths, when set, restricts the crawl to URLs whose path starts
+	// with one of these prefixes; ExcludePaths prefixes are always skipped
+	IncludePaths []string
+	ExcludePaths []string
+	// Include and Exclude are regular expressions matched against the whole
+	// URL. When Include is set, a URL must match one of them.
+	Include []string
+	Exclude []string
+}
+
+// Skip is a URL the crawl found but did not fetch, and why
+type Skip struct {
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+// scope is a compiled Scope
+type scope struct {
+	hosts        []string // lowercase; a leading "*." matches subdomains
+	includePaths []string
+	excludePaths []string
+	include      []*regexp.Regexp
+	exclude      []*regexp.Regexp
+}
+
+func compileScope(s Scope, root *url.URL) (*scope, error) {
+	sc := &scope{
+		hosts:        []string{normalizeURL(root).Host},
+		includePaths: pathPrefixes(s.IncludePaths),
+		excludePaths: pathPrefixes(s.ExcludePaths),
+	}
+	for _, h := range s.Hosts {
+		if h = scopeHost(h); h != "" {
+			sc.hosts = append(sc.hosts, h)
+		}
+	}
+
+	var err error
+	if sc.include, err = compilePatterns(s.Include); err != nil {
+		return nil, err
+	}
+	if sc.exclude, err = compilePatterns(s.Exclude); err != nil {
+		return nil, err
+	}
+	return sc, nil
+}
+
+// scopeHost normalizes a Scope.Hosts entry the way normalizeURL does a URL's
+// host. A URL is accepted where a host is expected, and a default port is
+// dropped, so "example.com:443" matches links that leave it out.
+func scopeHost(h string) string {
+	h = strings.ToLower(strings.TrimSpace(h))
+	if u, err := url.Parse(h); err == nil && u.Host != "" {
+		return normalizeURL(u).Host
+	}
+	if name, port, err := net.SplitHostPort(h); err == nil && (port == "80" || port == "443") {
+		return name
+	}
+	return h
+}
+
+// pathPrefixes makes every prefix start with a slash
+func pathPrefixes(prefixes []string) []string {
+	var out []string
+	for _, p := range prefixes {
+		if !strings.HasPrefix(p, "/") {
+			p = "/" + p
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// check returns why u is out of scope, or "" when it may be crawled
+func (s *scope) check(u *url.URL) string {
+	if !s.allowsHost(normalizeURL(u).Host) {
+		return fmt.Sprintf("host %s is out of scope", u.Host)
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	for _, p := range s.excludePaths {
+		if strings.HasPrefix(path, p) {
+			return fmt.Sprintf("excluded path %s", p)
+		}
+	}
+	if len(s.includePaths) > 0 && !hasAnyPrefix(path, s.includePaths) {
+		return "outside the included paths"
+	}
+
+	full := u.String()
+	for _, re := range s.exclude {
+		if re.MatchString(full) {
+			return fmt.Sprintf("matches exclude pattern %s", re)
+		}
+	}
+	if len(s.include) > 0 && !matchesAny(full, s.include) {
+		return "matches no include pattern"
+	}
+	return ""
+}
+
+// allowsHost matches host against the allowed hosts. Patterns without a port
+// match any port.
+func (s *scope) allowsHost(host string) bool {
+	host = strings.ToLower(host)
+	name := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		name = h
+	}
+	for _, pattern := range s.hosts {
+		candidate := name
+		if _, _, err := net.SplitHostPort(pattern); err == nil {
+			candidate = host
+		}
+		if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+			if candidate == suffix || strings.HasSuffix(candidate, "."+suffix) {
+				return true
+			}
+		} else if candidate == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(s string, res []*regexp.Regexp) bool {
+	for _, re := range res {
+		if re.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Limits from the sitemaps.org protocol, plus a cap on index nesting so a
+// self-referencing index can't loop
+const (
+	maxSitemapBytes = 50 * 1024 * 1024 // uncompressed
+	maxSitemapDepth = 3
+	maxSitemapFiles = 1000
+)
+
+// SitemapEntry is one page listed in a sitemap
+type SitemapEntry struct {
+	URL     string
+	LastMod time.Time // zero when the sitemap gives none
+}
+
+// sitemapDoc covers both <urlset> and <sitemapindex> documents
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []sitemapLoc `xml:"url"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// sitemapSources picks where to read sitemaps from: Options.Sitemaps if set,
+// else the ones robots.txt declares, else /sitemap.xml
+func (c *Crawler) sitemapSources() []string {
+	if len(c.opts.Sitemaps) > 0 {
+		return c.opts.Sitemaps
+	}
+	if len(c.robots.sitemaps) > 0 {
+		return c.robots.sitemaps
+	}
+	return []string{fmt.Sprintf("%s://%s/sitemap.xml", c.base.Scheme, c.base.Host)}
+}
+
+// loadSitemaps resolves every source, following index files, and returns the
+// listed pages in order with duplicates removed. Sources that fail to load
+// are skipped; a site without a sitemap is normal.
+func (c *Crawler) loadSitemaps(ctx context.Context, sources []string) []SitemapEntry {
+	var entries []SitemapEntry
+	seenPages := make(map[string]bool)
+	seenFiles := make(map[string]bool)
+
+	// local is whether src may be a file: only sitemaps named in
+	// Options.Sitemaps, and the ones a local index lists, may be
+	var resolve func(src string, local bool, depth int)
+	resolve = func(src string, local bool, depth int) {
+		if ctx.Err() != nil || depth > maxSitemapDepth || seenFiles[src] || len(seenFiles) >= maxSitemapFiles {
+			return
+		}
+		seenFiles[src] = true
+
+		if !local && !isHTTPURL(src) {
+			if c.opts.Verbose {
+				fmt.Fprintf(os.Stderr, "  sitemap %s: not an http(s) URL\n", src)
+			}
+			return
+		}
+		doc, err := c.readSitemap(ctx, src)
+		if err != nil {
+			if c.opts.Verbose {
+				fmt.Fprintf(os.Stderr, "  sitemap %s: %v\n", src, err)
+			}
+			return
+		}
+
+		for _, u := range doc.URLs {
+			loc := strings.TrimSpace(u.Loc)
+			if loc == "" || seenPages[loc] || isAssetPath(strings.ToLower(loc)) {
+				continue
+			}
+			seenPages[loc] = true
+			entries = append(entries, SitemapEntry{URL: loc, LastMod: parseLastMod(u.LastMod)})
+		}
+		for _, sm := range doc.Sitemaps {
+			if loc := strings.TrimSpace(sm.Loc); loc != "" {
+				resolve(resolveSitemapRef(src, loc), !isHTTPURL(src), depth+1)
+			}
+		}
+	}
+
+	for _, src := range sources {
+		resolve(src, slices.Contains(c.opts.Sitemaps, src), 0)
+	}
+	return entries
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// resolveSitemapRef makes an index entry absolute. Entries are normally
+// absolute URLs; relative ones resolve against the index that lists them,
+// which for a local index is its directory.
+func resolveSitemapRef(index, loc string) string {
+	if !isHTTPURL(index) {
+		if isHTTPURL(loc) || strings.HasPrefix(loc, "file://") || filepath.IsAbs(loc) {
+			return loc
+		}
+		dir := filepath.Dir(strings.TrimPrefix(index, "file://"))
+		return filepath.Join(dir, filepath.FromSlash(loc))
+	}
+	base, err := url.Parse(index)
+	if err != nil {
+		return loc
+	}
+	ref, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// readSitemap fetches (or opens, for local paths) and decodes one sitemap,
+// decompressing gzip whether or not the name ends in .gz
+func (c *Crawler) readSitemap(ctx context.Context, src string) (*sitemapDoc, error) {
+	body, err := c.openSitemap(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	br := bufio.NewReader(body)
+	var r io.Reader = br
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var doc sitemapDoc
+	dec := xml.NewDecoder(io.LimitReader(r, maxSitemapBytes))
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parsing: %w", err)
+	}
+	switch doc.XMLName.Local {
+	case "urlset", "sitemapindex":
+		return &doc, nil
+	}
+	return nil, fmt.Errorf("not a sitemap (root element <%s>)", doc.XMLName.Local)
+}
+
+func (c *Crawler) openSitemap(ctx context.Context, src string) (io.ReadCloser, error) {
+	if !isHTTPURL(src) {
+		return os.Open(strings.TrimPrefix(src, "file://"))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.opts.UserAgent)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// parseLastMod reads a W3C datetime, from a bare date up to fractional
+// seconds; anything else counts as unknown
+func parseLastMod(s string) time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{
+		time.RFC3339Nano,
+		"2006-01-02T15:04Z07:00",
+		"2006-01-02T15:04:05",
+		"2006-01-02",
+		"2006-01",
+		"2006",
+	} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,227 @@
+package crawler
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// State files within a crawl's directory. The journal is appended to while a
+// crawl runs; when it completes the journal becomes the previous crawl, whose
+// validators make the next crawl conditional.
+const (
+	journalFile  = "current.jsonl"
+	previousFile = "previous.jsonl"
+)
+
+// State persists a crawl to disk as it runs, so an interrupted crawl can
+// resume where it stopped and a finished one can be re-crawled with
+// conditional requests. It is kept per root URL and scope.
+type State struct {
+	dir string
+
+	journal  []stateRecord          // the unfinished crawl being resumed
+	previous map[string]stateRecord // last finished crawl's pages, by urlKey
+
+	mu  sync.Mutex
+	f   *os.File
+	err error // first write error; later writes are skipped
+}
+
+// stateRecord is one line of the journal: a URL joining the frontier, a URL
+// skipped, or a fetched page with the links found on it
+type stateRecord struct {
+	Kind   string   `json:"kind"` // "queue", "skip" or "page"
+	URL    string   `json:"url"`
+	Depth  int      `json:"depth,omitempty"`
+	Reason string   `json:"reason,omitempty"`
+	Page   *Page    `json:"page,omitempty"`
+	Links  []string `json:"links,omitempty"`
+}
+
+// StateDir returns the directory under stateDir that holds the crawl of root
+// with the given scope
+func StateDir(stateDir, root string, scope Scope) string {
+	id, _ := json.Marshal(struct {
+		Root  string
+		Scope Scope
+	}{cleanURL(root), scope})
+	sum := sha256.Sum256(id)
+	return filepath.Join(stateDir, "crawls", hex.EncodeToString(sum[:8]))
+}
+
+// OpenState loads the crawl state in dir, creating the directory if needed.
+// fresh discards any saved state first.
+func OpenState(dir string, fresh bool) (*State, error) {
+	if fresh {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("removing crawl state: %w", err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating crawl state directory: %w", err)
+	}
+
+	s := &State{dir: dir, previous: make(map[string]stateRecord)}
+	var err error
+	if s.journal, err = readStateFile(filepath.Join(dir, journalFile)); err != nil {
+		return nil, err
+	}
+	prev, err := readStateFile(filepath.Join(dir, previousFile))
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range prev {
+		if rec.Kind == "page" && rec.Page != nil && rec.Page.Error == "" {
+			s.previous[urlKey(rec.URL)] = rec
+		}
+	}
+	return s, nil
+}
+
+// readStateFile reads a journal. A missing file is empty, and a torn last
+// line from a crash is ignored.
+func readStateFile(path string) ([]stateRecord, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []stateRecord
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var rec stateRecord
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, sc.Err()
+}
+
+// Resuming reports how far the saved unfinished crawl got: pages fetched and
+// URLs still queued. Both are zero when there is nothing to resume.
+func (s *State) Resuming() (fetched, queued int) {
+	if s == nil {
+		return 0, 0
+	}
+	done := make(map[string]bool)
+	for _, rec := range s.journal {
+		if rec.Kind == "page" {
+			done[rec.URL] = true
+			fetched++
+		}
+	}
+	for _, rec := range s.journal {
+		if rec.Kind == "queue" && !done[rec.URL] {
+			queued++
+		}
+	}
+	return fetched, queued
+}
+
+// Previous returns how many pages the last finished crawl saved validators
+// or content for
+func (s *State) Previous() int {
+	if s == nil {
+		return 0
+	}
+	return len(s.previous)
+}
+
+// Err returns the first error writing the journal; the crawl carries on
+// without persisting after one
+func (s *State) Err() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// lookup returns the last finished crawl's page at rawURL
+func (s *State) lookup(rawURL string) (stateRecord, bool) {
+	if s == nil {
+		return stateRecord{}, false
+	}
+	rec, ok := s.previous[urlKey(rawURL)]
+	return rec, ok
+}
+
+// record appends rec to the journal
+func (s *State) record(rec stateRecord) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return
+	}
+	if s.f == nil {
+		if s.f, s.err = openJournal(filepath.Join(s.dir, journalFile)); s.err != nil {
+			return
+		}
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		s.err = err
+		return
+	}
+	// One write per line, so a crash tears at most the last one
+	_, s.err = s.f.Write(append(data, '\n'))
+}
+
+// openJournal opens path for appending, ending a line torn by a crash so
+// the next record starts on its own line
+func openJournal(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+		if _, err := f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			if _, err := f.Write([]byte{'\n'}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return f, nil
+}
+
+// finish closes the journal. A complete crawl's journal replaces the
+// previous crawl; an interrupted one stays to be resumed.
+func (s *State) finish(complete bool) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.f != nil {
+		if err := s.f.Close(); err != nil && s.err == nil {
+			s.err = err
+		}
+		s.f = nil
+	}
+	if complete && s.err == nil {
+		err := os.Rename(filepath.Join(s.dir, journalFile), filepath.Join(s.dir, previousFile))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			s.err = err
+		}
+	}
+}
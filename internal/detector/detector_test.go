@@ -0,0 +1,405 @@
+package detector
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestDetector(t *testing.T) *Detector {
+	t.Helper()
+	d, err := NewDetector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word, want string
+	}{
+		{"flicker", "flicker"},
+		{"flickers", "flicker"},
+		{"flickered", "flicker"},
+		{"flickering", "flicker"},
+		{"delve", "delv"},
+		{"delves", "delv"},
+		{"delved", "delv"},
+		{"delving", "delv"},
+		{"leaned", "lean"},
+		{"leans", "lean"},
+		{"caresses", "caress"},
+		{"ponies", "poni"},
+		{"hopping", "hop"},
+		{"relational", "relat"},
+		{"generalization", "gener"},
+		{"is", "is"},
+		{"Delve", "Delve"}, // not lowercase ASCII
+		{"café", "café"},   // not ASCII
+		{"don't", "don't"}, // apostrophes aren't stemmed
+	}
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestScanStemFamily(t *testing.T) {
+	d := newTestDetector(t)
+	res := d.Scan("The lamp flickered. Then the flickering stopped, and a flicker came back.")
+
+	var matches []string
+	for _, h := range res.Hits {
+		if h.Rule == "flicker" {
+			matches = append(matches, h.Match)
+		}
+	}
+	want := []string{"flickered", "flickering", "flicker"}
+	if len(matches) != len(want) {
+		t.Fatalf("flicker hits = %q, want %q", matches, want)
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("hit %d = %q, want %q", i, matches[i], want[i])
+		}
+	}
+}
+
+func TestScanWordWeights(t *testing.T) {
+	d := newTestDetector(t)
+	tests := []struct {
+		text string
+		want map[string]float64 // match → weight
+	}{
+		{"It flickered, a flicker, still flickering, and flickers.",
+			map[string]float64{"flickered": 0.985, "flicker": 0.94, "flickering": 0.925, "flickers": 0.94}},
+		{"She leaned in. He leans back.", map[string]float64{"leaned": 0.821, "leans": 0.627}},
+		{"Lean startup and lean meat.", map[string]float64{}},
+	}
+	for _, tt := range tests {
+		got := make(map[string]float64)
+		for _, h := range d.Scan(tt.text).Hits {
+			if h.Type == "word" {
+				got[h.Match] = h.Weight
+			}
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%q: hits %v, want %v", tt.text, got, tt.want)
+			continue
+		}
+		for m, w := range tt.want {
+			if diff := got[m] - w; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("%q: %s weighs %v, want %v", tt.text, m, got[m], w)
+			}
+		}
+	}
+}
+
+func TestResolveOverlaps(t *testing.T) {
+	hit := func(rule, typ string, start, end int, weight float64) Hit {
+		return Hit{Rule: rule, Type: typ, Offset: start, EndOffset: end, Weight: weight}
+	}
+	tests := []struct {
+		name       string
+		hits       []Hit
+		kept       []string
+		alsoByRule map[string][]string
+	}{
+		{
+			name:       "coincident spans keep the heavier",
+			hits:       []Hit{hit("word", "word", 0, 5, 1), hit("preset", "word", 0, 5, 2)},
+			kept:       []string{"preset"},
+			alsoByRule: map[string][]string{"preset": {"word"}},
+		},
+		{
+			name:       "weight ties go to the pattern",
+			hits:       []Hit{hit("word", "word", 0, 5, 1), hit("pattern", "pattern", 0, 5, 1)},
+			kept:       []string{"pattern"},
+			alsoByRule: map[string][]string{"pattern": {"word"}},
+		},
+		{
+			name:       "contained span folds",
+			hits:       []Hit{hit("pattern", "pattern", 0, 30, 2), hit("word", "word", 10, 15, 1)},
+			kept:       []string{"pattern"},
+			alsoByRule: map[string][]string{"pattern": {"word"}},
+		},
+		{
+			name:       "contained hit outweighs its container",
+			hits:       []Hit{hit("pattern", "pattern", 0, 30, 1), hit("word", "word", 10, 15, 2)},
+			kept:       []string{"word"},
+			alsoByRule: map[string][]string{"word": {"pattern"}},
+		},
+		{
+			name:       "partial overlap folds into the heavier",
+			hits:       []Hit{hit("trigram", "trigram", 0, 12, 1), hit("word", "word", 8, 20, 2)},
+			kept:       []string{"word"},
+			alsoByRule: map[string][]string{"word": {"trigram"}},
+		},
+		{
+			name:       "one hit overlapping two",
+			hits:       []Hit{hit("a", "word", 0, 5, 1), hit("pattern", "pattern", 0, 20, 3), hit("b", "word", 10, 15, 2)},
+			kept:       []string{"pattern"},
+			alsoByRule: map[string][]string{"pattern": {"b", "a"}},
+		},
+		{
+			name: "touching spans keep both",
+			hits: []Hit{hit("a", "word", 0, 5, 1), hit("b", "word", 5, 10, 1)},
+			kept: []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		got := resolveOverlaps(tt.hits)
+		var kept []string
+		for _, h := range got {
+			kept = append(kept, h.Rule)
+			var also []string
+			for _, m := range h.AlsoMatched {
+				also = append(also, m.Rule)
+			}
+			if want := tt.alsoByRule[h.Rule]; strings.Join(also, ",") != strings.Join(want, ",") {
+				t.Errorf("%s: %s also matched %q, want %q", tt.name, h.Rule, also, want)
+			}
+		}
+		if strings.Join(kept, ",") != strings.Join(tt.kept, ",") {
+			t.Errorf("%s: kept %q, want %q", tt.name, kept, tt.kept)
+		}
+	}
+}
+
+func TestLineIndexPosition(t *testing.T) {
+	// "é" is 2 bytes and 1 UTF-16 unit; "😀" is 4 bytes and 2 UTF-16 units
+	text := "abc\néx😀y\n\nlast"
+	li := newLineIndex(text)
+	tests := []struct {
+		pos                          int
+		line, col, utf16Col, byteCol int
+	}{
+		{0, 1, 1, 1, 1},
+		{3, 1, 4, 4, 4},  // the newline itself
+		{4, 2, 1, 1, 1},  // é
+		{6, 2, 2, 2, 3},  // x
+		{7, 2, 3, 3, 4},  // 😀
+		{11, 2, 4, 5, 8}, // y
+		{13, 3, 1, 1, 1}, // empty line
+		{14, 4, 1, 1, 1}, // last
+		{18, 4, 5, 5, 5}, // end of text
+	}
+	for _, tt := range tests {
+		line, col, utf16Col, byteCol := li.position(tt.pos)
+		if line != tt.line || col != tt.col || utf16Col != tt.utf16Col || byteCol != tt.byteCol {
+			t.Errorf("position(%d) = %d:%d utf16 %d byte %d, want %d:%d utf16 %d byte %d",
+				tt.pos, line, col, utf16Col, byteCol, tt.line, tt.col, tt.utf16Col, tt.byteCol)
+		}
+	}
+}
+
+func TestScanHitColumns(t *testing.T) {
+	d := newTestDetector(t)
+	res := d.Scan("Intro line here.\n😀 Café owners delve deeper every day.")
+	for _, h := range res.Hits {
+		if h.Rule != "delve" {
+			continue
+		}
+		if h.Line != 2 || h.Column != 15 || h.UTF16Column != 16 || h.ByteColumn != 19 {
+			t.Errorf("delve at %d:%d utf16 %d byte %d, want 2:15 utf16 16 byte 19",
+				h.Line, h.Column, h.UTF16Column, h.ByteColumn)
+		}
+		if h.EndLine != 2 || h.EndColumn != 20 || h.EndUTF16Column != 21 {
+			t.Errorf("delve ends at %d:%d utf16 %d, want 2:20 utf16 21", h.EndLine, h.EndColumn, h.EndUTF16Column)
+		}
+		return
+	}
+	t.Fatal("no delve hit")
+}
+
+func TestScanTrigramModes(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry TrigramEntry
+		text  string
+		want  []string // matched spans
+	}{
+		{"exact", TrigramEntry{Phrase: "at the end of the day", Mode: "exact"},
+			"At the end of the day, we ship.", []string{"At the end of the day"}},
+		{"exact across a hyphen", TrigramEntry{Phrase: "state of the art", Mode: "exact"},
+			"A state-of-the-art tool.", []string{"state-of-the-art"}},
+		{"exact rejects punctuation between words", TrigramEntry{Phrase: "at the end of the day", Mode: "exact"},
+			"At the end, of the day.", nil},
+		{"exact rejects extra words", TrigramEntry{Phrase: "one last time", Mode: "exact"},
+			"One more last time.", nil},
+		{"ordered allows words between", TrigramEntry{Phrase: "voice barely whisper"},
+			"Her voice was barely a whisper.", []string{"voice was barely a whisper"}},
+		{"ordered needs whole words", TrigramEntry{Phrase: "one last time"},
+			"One lasting timeline.", nil},
+		{"ordered keeps the order", TrigramEntry{Phrase: "voice barely whisper"},
+			"A whisper, barely a voice.", nil},
+		{"ordered stays inside the window", TrigramEntry{Phrase: "voice barely whisper", Window: 4},
+			"Her voice was barely above a whisper.", nil},
+		{"ordered reads negative contractions", TrigramEntry{Phrase: "could help but"},
+			"I couldn't help but smile.", []string{"couldn't help but"}},
+		{"window in any order", TrigramEntry{Phrase: "voice barely whisper", Mode: "window"},
+			"A whisper, her voice barely.", []string{"whisper, her voice barely"}},
+		{"window too narrow", TrigramEntry{Phrase: "voice barely whisper", Mode: "window", Window: 3},
+			"A whisper, her voice barely.", nil},
+		{"matches don't overlap", TrigramEntry{Phrase: "one last time", Mode: "exact"},
+			"One last time, one last time.", []string{"One last time", "one last time"}},
+	}
+	d := &Detector{}
+	for _, tt := range tests {
+		lower := foldCase(tt.text)
+		result := &ScanResult{}
+		d.scanTrigram(lower, tokenize(lower), tt.entry, result)
+		var got []string
+		for _, h := range result.Hits {
+			got = append(got, tt.text[h.Offset:h.EndOffset])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: matched %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestContextRule(t *testing.T) {
+	tests := []struct {
+		name   string
+		rule   ContextRule
+		text   string
+		word   string // the match; its first occurrence is checked
+		pass   bool
+		reason string
+	}{
+		{"require found", ContextRule{Require: []string{"power"}}, "harness the power of ai", "harness", true, ""},
+		{"require missing", ContextRule{Require: []string{"power"}}, "harness the horse", "harness", false, "none of power nearby"},
+		{"require matches stems", ContextRule{Require: []string{"tool"}}, "an ecosystem of tools", "ecosystem", true, ""},
+		{"forbid", ContextRule{Forbid: []string{"forest"}}, "the forest ecosystem thrives", "ecosystem", false, `"forest" nearby`},
+		{"forbid beyond the span", ContextRule{Forbid: []string{"forest"}, Span: 2}, "forest trees and many birds in the ecosystem", "ecosystem", true, ""},
+		{"forbid in the previous sentence", ContextRule{Forbid: []string{"door"}}, "unlock the door. unlock your potential.", "unlock your", true, ""},
+		{"forbid in the next sentence", ContextRule{Forbid: []string{"forest"}}, "this ecosystem of tools. the forest ecosystem thrives.", "ecosystem", true, ""},
+		{"decimal point is not a sentence end", ContextRule{Forbid: []string{"forest"}}, "forest 2.5 ecosystem", "ecosystem", false, `"forest" nearby`},
+		{"verb after to", ContextRule{POS: "verb"}, "how to harness ai", "harness", true, ""},
+		{"verb before an object", ContextRule{POS: "verb"}, "harness the power", "harness", true, ""},
+		{"noun after the", ContextRule{POS: "verb"}, "the harness broke", "harness", false, "not used as a verb"},
+		{"no cue across a sentence end", ContextRule{POS: "verb"}, "go to. harness broke", "harness", false, "not used as a verb"},
+		{"noun wanted", ContextRule{POS: "noun"}, "a harness", "harness", true, ""},
+		{"regex", ContextRule{Regex: `power\s+of`}, "harness the power of ai", "harness", true, ""},
+		{"regex fails", ContextRule{Regex: `power\s+of`}, "harness the wind", "harness", false, `context does not match /power\s+of/`},
+		{"not_regex", ContextRule{NotRegex: `safety`}, "a safety harness", "harness", false, "context matches /safety/"},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.compile(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		start := strings.Index(tt.text, tt.word)
+		end := start + len(strings.Fields(tt.word)[0])
+		pass, reason := tt.rule.check(tt.text, tokenize(tt.text), start, end)
+		if pass != tt.pass || reason != tt.reason {
+			t.Errorf("%s: check = %v %q, want %v %q", tt.name, pass, reason, tt.pass, tt.reason)
+		}
+	}
+}
+
+func TestScanGuards(t *testing.T) {
+	d, err := NewDetectorWithPresets([]string{"marketing", "technical"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		text             string
+		rule             string
+		hits, suppressed int
+	}{
+		{"Unlock the door. Unlock your potential.", "unlock", 1, 1},
+		{"This ecosystem of tools. The forest ecosystem thrives.", "ecosystem", 1, 1},
+		{"The harness broke.", "harness", 0, 2},
+		{"We harness the wind.", "harness", 2, 0},
+	}
+	for _, tt := range tests {
+		res := d.Scan(tt.text)
+		hits, suppressed := 0, 0
+		for _, h := range res.Hits {
+			if h.Rule == tt.rule {
+				hits++
+				hits += len(h.AlsoMatched)
+			}
+		}
+		for _, h := range res.Suppressed {
+			if h.Rule == tt.rule {
+				suppressed++
+			}
+		}
+		if hits != tt.hits || suppressed != tt.suppressed {
+			t.Errorf("%q: %d %s hits, %d suppressed; want %d, %d", tt.text, hits, tt.rule, suppressed, tt.hits, tt.suppressed)
+		}
+	}
+}
+
+func TestScanOverlapKeepsHeaviest(t *testing.T) {
+	d := newTestDetector(t)
+	res := d.Scan("It is not just a tapestry, but a promise. The light was not just flickering but fading.")
+	weights := make(map[string]float64)
+	for _, h := range res.Hits {
+		weights[h.Rule] += h.Weight
+		if h.Rule == "not_just_but" {
+			t.Errorf("not_just_but kept over %q", h.Match)
+		}
+	}
+	if weights["tapestry"] != 0.5 || weights["flicker"] != 0.925 {
+		t.Errorf("weights = %v, want tapestry 0.5 and flicker 0.925, each once", weights)
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"Delve DEEPER", "delve deeper"},
+		{"Café ÉTÉ", "café été"},
+		{"ΣΟΦΙΑ", "σοφια"},
+		{"İstanbul", "İstanbul"}, // lowercase 'i̇' is wider; kept as is
+		{"a\xffB", "a\xffb"},     // invalid bytes are copied
+		{"Kelvin K", "kelvin K"}, // 'K' (3 bytes) lowers to 'k' (1 byte)
+	}
+	for _, tt := range tests {
+		got := foldCase(tt.in)
+		if got != tt.want {
+			t.Errorf("foldCase(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		if len(got) != len(tt.in) {
+			t.Errorf("foldCase(%q) changed the length from %d to %d", tt.in, len(tt.in), len(got))
+		}
+	}
+}
+
+func TestMakeSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 12)
+	tests := []struct {
+		name, text, match string
+		want              Snippet
+	}{
+		{"short line", "We delve into it.", "delve", Snippet{"We ", "delve", " into it."}},
+		{"context stays on the line", "first line\nWe delve in\nlast line", "delve", Snippet{"We ", "delve", " in"}},
+		{"long context is cut", long + "delve " + long, "delve",
+			Snippet{"…" + strings.Repeat("word ", 8), "delve", strings.Repeat(" word", 8) + "…"}},
+		{"whitespace collapses", "We\t delve   into\r\nit", "delve", Snippet{"We ", "delve", " into"}},
+		{"trailing space leaves the highlight", "not just this, but that", "not just this, but ", Snippet{"", "not just this, but", " that"}},
+		{"original case is quoted", "WE DELVE IN", "DELVE", Snippet{"WE ", "DELVE", " IN"}},
+	}
+	for _, tt := range tests {
+		start := strings.Index(tt.text, tt.match)
+		got := makeSnippet(tt.text, start, start+len(tt.match))
+		if *got != tt.want {
+			t.Errorf("%s: snippet = %q, want %q", tt.name, *got, tt.want)
+		}
+	}
+}
+
+func TestScanQuotesOriginalCase(t *testing.T) {
+	d := newTestDetector(t)
+	res := d.Scan("CAFÉ OWNERS DELVE INTO THE TAPESTRY.")
+	var matches []string
+	for _, h := range res.Hits {
+		matches = append(matches, h.Match)
+	}
+	if strings.Join(matches, ",") != "TAPESTRY,DELVE" && strings.Join(matches, ",") != "DELVE,TAPESTRY" {
+		t.Errorf("matches = %q, want DELVE and TAPESTRY as written", matches)
+	}
+}
@@ -0,0 +1,172 @@
+package detector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ContextRule limits an entry to the usages that are actually slop, so
+// dual-use words like "distill" or "ecosystem" skip their literal senses.
+// All configured checks must pass for a hit to count.
+type ContextRule struct {
+	Require  []string `json:"require,omitempty"`   // at least one must appear within Span tokens
+	Forbid   []string `json:"forbid,omitempty"`    // none may appear within Span tokens
+	Span     int      `json:"span,omitempty"`      // tokens either side of the match, within its sentence; default 4
+	POS      string   `json:"pos,omitempty"`       // "verb" or "noun" (heuristic)
+	Regex    string   `json:"regex,omitempty"`     // must match the surrounding text
+	NotRegex string   `json:"not_regex,omitempty"` // must not match the surrounding text
+
+	re    *regexp.Regexp
+	notRe *regexp.Regexp
+}
+
+const defaultGuardSpan = 4
+
+// compile prepares the regex guards; called once when the entry is loaded
+func (c *ContextRule) compile() error {
+	if c.Regex != "" {
+		re, err := regexp.Compile("(?i)" + c.Regex)
+		if err != nil {
+			return fmt.Errorf("context regex: %w", err)
+		}
+		c.re = re
+	}
+	if c.NotRegex != "" {
+		re, err := regexp.Compile("(?i)" + c.NotRegex)
+		if err != nil {
+			return fmt.Errorf("context not_regex: %w", err)
+		}
+		c.notRe = re
+	}
+	switch c.POS {
+	case "", "verb", "noun":
+	default:
+		return fmt.Errorf("unknown pos hint %q", c.POS)
+	}
+	return nil
+}
+
+// Words that usually precede a verb ("to harness", "we harness")
+var verbCues = map[string]bool{
+	"to": true, "will": true, "would": true, "can": true, "could": true,
+	"should": true, "may": true, "might": true, "must": true, "shall": true,
+	"i": true, "we": true, "you": true, "they": true, "it": true, "this": true,
+	"that": true, "which": true, "who": true, "also": true, "further": true,
+	"helps": true, "help": true, "let": true, "lets": true, "don": true,
+	"doesn": true, "didn": true, "not": true,
+}
+
+// Words that usually precede a noun ("the harness", "a safety harness")
+var nounCues = map[string]bool{
+	"a": true, "an": true, "the": true, "this": true, "that": true,
+	"these": true, "those": true, "my": true, "your": true, "our": true,
+	"their": true, "his": true, "her": true, "its": true, "each": true,
+	"every": true, "of": true,
+}
+
+// Determiners after a word suggest it is a transitive verb ("harness the power")
+var objectCues = map[string]bool{
+	"the": true, "a": true, "an": true, "this": true, "that": true,
+	"these": true, "those": true, "our": true, "your": true, "their": true,
+	"its": true, "his": true, "her": true, "my": true, "it": true, "them": true,
+}
+
+// check reports whether the match at lowerText[start:end] passes the rule.
+// When it fails, reason says which check suppressed it.
+func (c *ContextRule) check(lowerText string, tokens []token, start, end int) (bool, string) {
+	span := c.Span
+	if span <= 0 {
+		span = defaultGuardSpan
+	}
+
+	// Token range covering the match
+	first := sort.Search(len(tokens), func(i int) bool { return tokens[i].end > start })
+	last := sort.Search(len(tokens), func(i int) bool { return tokens[i].start >= end }) - 1
+	if first >= len(tokens) || last < first {
+		return true, ""
+	}
+
+	// The window stops at the ends of the match's sentence, so a guard word
+	// in one sentence doesn't excuse (or condemn) the next
+	lo := first
+	for lo > 0 && first-lo < span && !sentenceBreak(lowerText[tokens[lo-1].end:tokens[lo].start]) {
+		lo--
+	}
+	hi := last
+	for hi+1 < len(tokens) && hi-last < span && !sentenceBreak(lowerText[tokens[hi].end:tokens[hi+1].start]) {
+		hi++
+	}
+
+	neighbors := make(map[string]bool)
+	for i := lo; i <= hi; i++ {
+		if i >= first && i <= last {
+			continue
+		}
+		neighbors[tokens[i].text] = true
+		neighbors[tokens[i].stem] = true
+	}
+
+	if len(c.Require) > 0 {
+		found := false
+		for _, w := range c.Require {
+			w = strings.ToLower(w)
+			if neighbors[w] || neighbors[Stem(w)] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, fmt.Sprintf("none of %s nearby", strings.Join(c.Require, ", "))
+		}
+	}
+
+	for _, w := range c.Forbid {
+		lw := strings.ToLower(w)
+		if neighbors[lw] || neighbors[Stem(lw)] {
+			return false, fmt.Sprintf("%q nearby", w)
+		}
+	}
+
+	if c.POS != "" {
+		var prev, next string
+		if lo < first {
+			prev = tokens[first-1].text
+		}
+		if hi > last {
+			next = tokens[last+1].text
+		}
+		isVerb := verbCues[prev] || objectCues[next]
+		isNoun := nounCues[prev] && !verbCues[prev]
+		switch c.POS {
+		case "verb":
+			if !isVerb || isNoun {
+				return false, "not used as a verb"
+			}
+		case "noun":
+			if !isNoun {
+				return false, "not used as a noun"
+			}
+		}
+	}
+
+	if c.re != nil || c.notRe != nil {
+		around := lowerText[tokens[lo].start:tokens[hi].end]
+		if c.re != nil && !c.re.MatchString(around) {
+			return false, fmt.Sprintf("context does not match /%s/", c.Regex)
+		}
+		if c.notRe != nil && c.notRe.MatchString(around) {
+			return false, fmt.Sprintf("context matches /%s/", c.NotRegex)
+		}
+	}
+
+	return true, ""
+}
+
+// sentenceBreak reports whether the text between two tokens ends a sentence:
+// a full stop, question or exclamation mark followed by whitespace
+func sentenceBreak(gap string) bool {
+	i := strings.IndexAny(gap, ".!?")
+	return i >= 0 && strings.ContainsAny(gap[i:], " \t\r\n")
+}
@@ -0,0 +1,78 @@
+package detector
+
+import "sort"
+
+// Type precedence for breaking weight ties; structural patterns explain the
+// most context, single words the least
+var typeRank = map[string]int{"pattern": 0, "trigram": 1, "word": 2}
+
+// resolveOverlaps keeps one hit per stretch of text so it is not scored
+// twice, e.g. "tapestry" as a banlist word and inside the not_just_but
+// pattern, or a word a preset repeats from the base list.
+//
+// Hits are ranked by weight (highest first), then by longer span, then by type
+// (pattern, trigram, word), then by earlier position and rule name. Walking
+// that ranking, a hit is kept unless its span overlaps a hit already kept, in
+// which case it is recorded in the first such kept hit's AlsoMatched and does
+// not count toward the score. The kept hit is the heaviest of the ones it
+// overlaps, whichever contains the other. Kept hits retain their original
+// order.
+func resolveOverlaps(hits []Hit) []Hit {
+	if len(hits) < 2 {
+		return hits
+	}
+
+	ranked := make([]int, len(hits))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool {
+		ha, hb := &hits[ranked[a]], &hits[ranked[b]]
+		if ha.Weight != hb.Weight {
+			return ha.Weight > hb.Weight
+		}
+		if la, lb := ha.EndOffset-ha.Offset, hb.EndOffset-hb.Offset; la != lb {
+			return la > lb
+		}
+		if typeRank[ha.Type] != typeRank[hb.Type] {
+			return typeRank[ha.Type] < typeRank[hb.Type]
+		}
+		if ha.Offset != hb.Offset {
+			return ha.Offset < hb.Offset
+		}
+		return ha.Rule < hb.Rule
+	})
+
+	kept := make([]bool, len(hits))
+	var keptIdx []int // in rank order
+	for _, i := range ranked {
+		h := &hits[i]
+		owner := -1
+		for _, k := range keptIdx {
+			if hits[k].Offset < h.EndOffset && h.Offset < hits[k].EndOffset {
+				owner = k
+				break
+			}
+		}
+		if owner == -1 {
+			kept[i] = true
+			keptIdx = append(keptIdx, i)
+			continue
+		}
+		hits[owner].AlsoMatched = append(hits[owner].AlsoMatched, RuleMatch{
+			Type:     h.Type,
+			Rule:     h.Rule,
+			Match:    h.Match,
+			Severity: h.Severity,
+			Weight:   h.Weight,
+		})
+	}
+
+	resolved := make([]Hit, 0, len(keptIdx))
+	for i, h := range hits {
+		if kept[i] {
+			resolved = append(resolved, h)
+		}
+	}
+	return resolved
+}
@@ -1,7 +1,9 @@
 package detector
 
 import (
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -18,7 +20,12 @@ type WordEntry struct {
 	Word      string  `json:"word"`
 	PctModels float64 `json:"pct_models"`
 	Severity  string  `json:"severity"`
-	Note      string  `json:"note,omitempty"`
+	Match     string  `json:"match,omitempty"` // "exact" (default) or "stem"
+	// Forms gives a stem entry's inflections their own pct_models; forms
+	// not listed use the entry's
+	Forms   map[string]float64 `json:"forms,omitempty"`
+	Context *ContextRule       `json:"context,omitempty"`
+	Note    string             `json:"note,omitempty"`
 }
 
 type WordData struct {
@@ -26,10 +33,13 @@ type WordData struct {
 }
 
 type TrigramEntry struct {
-	Phrase    string  `json:"phrase"`
-	PctModels float64 `json:"pct_models"`
-	Severity  string  `json:"severity"`
-	Note      string  `json:"note,omitempty"`
+	Phrase    string       `json:"phrase"`
+	PctModels float64      `json:"pct_models"`
+	Severity  string       `json:"severity"`
+	Mode      string       `json:"mode,omitempty"`   // "exact", "ordered" (default) or "window"
+	Window    int          `json:"window,omitempty"` // max tokens a match may span; default phrase length + 3
+	Context   *ContextRule `json:"context,omitempty"`
+	Note      string       `json:"note,omitempty"`
 }
 
 type TrigramData struct {
@@ -49,28 +59,59 @@ type PatternData struct {
 	Patterns []PatternEntry `json:"patterns"`
 }
 
-// Hit represents a single slop detection
+// Hit represents a single slop detection. Lines and columns are 1-based;
+// Column counts runes, UTF16Column counts UTF-16 code units (LSP, SARIF) and
+// ByteColumn counts bytes. End positions are exclusive.
 type Hit struct {
-	Line     int     `json:"line"`
-	Column   int     `json:"column"`
+	Line           int `json:"line"`
+	Column         int `json:"column"`
+	ByteColumn     int `json:"byte_column"`
+	UTF16Column    int `json:"utf16_column"`
+	EndLine        int `json:"end_line"`
+	EndColumn      int `json:"end_column"`
+	EndUTF16Column int `json:"end_utf16_column"`
+	Offset         int `json:"offset"`     // byte offset of the match start
+	EndOffset      int `json:"end_offset"` // byte offset just past the match
+
+	Match    string   `json:"match"` // original text, cut at 60 runes
+	Snippet  *Snippet `json:"snippet,omitempty"`
+	Type     string   `json:"type"` // "word", "trigram", "pattern"
+	Rule     string   `json:"rule"` // word, phrase or pattern name that matched
+	Detail   string   `json:"detail"`
+	Severity string   `json:"severity"`
+	Weight   float64  `json:"weight"`           // frequency-ratio based weight
+	Family   string   `json:"family,omitempty"` // banlist entry when Match is an inflection or span
+
+	SuppressedBy string      `json:"suppressed_by,omitempty"` // why a context rule dropped this hit
+	AlsoMatched  []RuleMatch `json:"also_matched,omitempty"`  // overlapping rules folded into this hit
+}
+
+// RuleMatch identifies a rule whose hit overlapped a higher-weight hit
+type RuleMatch struct {
+	Type     string  `json:"type"`
+	Rule     string  `json:"rule"`
 	Match    string  `json:"match"`
-	Type     string  `json:"type"` // "word", "trigram", "pattern"
-	Detail   string  `json:"detail"`
 	Severity string  `json:"severity"`
-	Weight   float64 `json:"weight"` // frequency-ratio based weight
+	Weight   float64 `json:"weight"`
 }
 
 // ScanResult is the result of scanning a single file
 type ScanResult struct {
-	Path      string  `json:"path"`
-	Hits      []Hit   `json:"hits"`
-	Score     float64 `json:"score"`
-	LineCount int     `json:"line_count"`
-	WordCount int     `json:"word_count"`
-	Density   float64 `json:"density"` // hits per 1000 words
-	Rating    string  `json:"rating"`  // "clean", "moderate", "heavy"
+	Path       string  `json:"path"`
+	Hits       []Hit   `json:"hits"`
+	Suppressed []Hit   `json:"suppressed,omitempty"` // hits dropped by context rules
+	Score      float64 `json:"score"`
+	LineCount  int     `json:"line_count"`
+	WordCount  int     `json:"word_count"`
+	Density    float64 `json:"density"` // hits per 1000 words
+	Rating     string  `json:"rating"`  // "clean", "moderate", "heavy"
 }
 
+// Schema identifies how Scan turns rules into hits. Bump it whenever matching,
+// overlap folding, guards or scoring change, so cached results from the old
+// behavior are not reused.
+const Schema = 2
+
 // Detector is the main slop detection engine
 type Detector struct {
 	words    []WordEntry
@@ -121,7 +162,7 @@ func NewDetectorWithOptions(opts DetectorOptions) (*Detector, error) {
 	if err := json.Unmarshal(wordBytes, &wordData); err != nil {
 		return nil, fmt.Errorf("parsing word data: %w", err)
 	}
-	d.words = wordData.Words
+	d.addWords(wordData.Words)
 
 	// Load trigram data
 	trigramBytes, err := dataFS.ReadFile("data/trigrams.json")
@@ -132,7 +173,7 @@ func NewDetectorWithOptions(opts DetectorOptions) (*Detector, error) {
 	if err := json.Unmarshal(trigramBytes, &trigramData); err != nil {
 		return nil, fmt.Errorf("parsing trigram data: %w", err)
 	}
-	d.trigrams = trigramData.Trigrams
+	d.addTrigrams(trigramData.Trigrams)
 
 	// Load and compile base pattern data
 	patternBytes, err := dataFS.ReadFile("data/patterns.json")
@@ -179,8 +220,8 @@ func (d *Detector) loadPreset(name string, presetDir string) error {
 		return fmt.Errorf("parsing preset %q: %w", name, err)
 	}
 
-	d.words = append(d.words, preset.Words...)
-	d.trigrams = append(d.trigrams, preset.Trigrams...)
+	d.addWords(preset.Words)
+	d.addTrigrams(preset.Trigrams)
 
 	for _, p := range preset.Patterns {
 		re, err := regexp.Compile("(?i)" + p.Regex)
@@ -194,6 +235,32 @@ func (d *Detector) loadPreset(name string, presetDir string) error {
 	return nil
 }
 
+// addWords appends word entries, compiling their context rules
+func (d *Detector) addWords(words []WordEntry) {
+	for _, w := range words {
+		if w.Context != nil {
+			if err := w.Context.compile(); err != nil {
+				fmt.Printf("warning: skipping word %q: %v\n", w.Word, err)
+				continue
+			}
+		}
+		d.words = append(d.words, w)
+	}
+}
+
+// addTrigrams appends trigram entries, compiling their context rules
+func (d *Detector) addTrigrams(trigrams []TrigramEntry) {
+	for _, t := range trigrams {
+		if t.Context != nil {
+			if err := t.Context.compile(); err != nil {
+				fmt.Printf("warning: skipping trigram %q: %v\n", t.Phrase, err)
+				continue
+			}
+		}
+		d.trigrams = append(d.trigrams, t)
+	}
+}
+
 func resolvePreset(name string, presetDir string) ([]byte, error) {
 	// 1. Direct file path
 	if strings.Contains(name, "/") || strings.HasSuffix(name, ".json") {
@@ -224,6 +291,23 @@ func resolvePreset(name string, presetDir string) ([]byte, error) {
 	return data, nil
 }
 
+// RuleSetHash returns a hex SHA-256 over every loaded word, trigram and
+// pattern entry. It changes whenever presets or rule data change, so results
+// can record which rules produced them.
+func (d *Detector) RuleSetHash() string {
+	patterns := make([]PatternEntry, len(d.patterns))
+	for i, p := range d.patterns {
+		patterns[i] = p.entry
+	}
+	data, _ := json.Marshal(struct {
+		Words    []WordEntry
+		Trigrams []TrigramEntry
+		Patterns []PatternEntry
+	}{d.words, d.trigrams, patterns})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
 // ListPresets returns the names of all available embedded presets
 func ListPresets() ([]string, error) {
 	entries, err := dataFS.ReadDir("data/presets")
@@ -266,26 +350,45 @@ func (d *Detector) Scan(text string) *ScanResult {
 		return result
 	}
 
-	lowerText := strings.ToLower(text)
+	// Folding keeps byte offsets aligned with text, so hits can quote the original
+	lowerText := foldCase(text)
 
-	// Build a line index for mapping character positions to line numbers
-	lineOffsets := buildLineOffsets(text)
+	// Stem words and trigrams share one tokenization pass
+	tokens := tokenize(lowerText)
 
 	// 1. Scan for banlist words
 	for _, w := range d.words {
-		d.scanWord(lowerText, w, lineOffsets, result)
+		if w.Match == "stem" {
+			d.scanWordStem(lowerText, tokens, w, result)
+			continue
+		}
+		d.scanWord(lowerText, tokens, w, result)
 	}
 
 	// 2. Scan for trigrams
 	for _, t := range d.trigrams {
-		d.scanTrigram(lowerText, t, lineOffsets, result)
+		d.scanTrigram(lowerText, tokens, t, result)
 	}
 
 	// 3. Scan for structural patterns
 	for _, p := range d.patterns {
-		d.scanPattern(lowerText, p, lineOffsets, result)
+		d.scanPattern(lowerText, p, result)
+	}
+
+	// 4. Quote the original text for every hit, then map offsets to positions
+	idx := newLineIndex(text)
+	for _, hits := range [][]Hit{result.Hits, result.Suppressed} {
+		for i := range hits {
+			h := &hits[i]
+			h.Match = truncateRunes(text[h.Offset:h.EndOffset], maxMatchRunes)
+			h.Snippet = makeSnippet(text, h.Offset, h.EndOffset)
+			idx.locate(h)
+		}
 	}
 
+	// 5. Fold overlapping hits so one span is only scored once
+	result.Hits = resolveOverlaps(result.Hits)
+
 	// Calculate score
 	result.Score = d.calculateScore(result)
 	result.Density = float64(len(result.Hits)) / float64(result.WordCount) * 1000
@@ -301,99 +404,202 @@ func (d *Detector) Scan(text string) *ScanResult {
 	return result
 }
 
-func (d *Detector) scanWord(lowerText string, w WordEntry, lineOffsets []int, result *ScanResult) {
+func (d *Detector) scanWord(lowerText string, tokens []token, w WordEntry, result *ScanResult) {
 	// Match whole words only
 	pattern := regexp.MustCompile(`\b` + regexp.QuoteMeta(w.Word) + `\b`)
 	matches := pattern.FindAllStringIndex(lowerText, -1)
 
 	for _, m := range matches {
-		line, col := posToLineCol(m[0], lineOffsets)
-		result.Hits = append(result.Hits, Hit{
-			Line:     line,
-			Column:   col,
-			Match:    lowerText[m[0]:m[1]],
-			Type:     "word",
-			Detail:   fmt.Sprintf("%.1f%% of models overuse this word", w.PctModels),
-			Severity: w.Severity,
-			Weight:   w.PctModels / 100.0,
+		addHit(result, w.Context, lowerText, tokens, m[0], m[1], Hit{
+			Type:      "word",
+			Rule:      w.Word,
+			Detail:    fmt.Sprintf("%.1f%% of models overuse this word", w.PctModels),
+			Severity:  w.Severity,
+			Weight:    w.PctModels / 100.0,
+			Offset:    m[0],
+			EndOffset: m[1],
 		})
 	}
 }
 
-func (d *Detector) scanTrigram(lowerText string, t TrigramEntry, lineOffsets []int, result *ScanResult) {
-	// Trigrams need fuzzy matching — words may not be adjacent
-	// Search for all words within a 5-word window
-	words := strings.Fields(t.Phrase)
+// scanWordStem matches every token sharing the entry's Porter stem, so
+// "delve" also catches "delves", "delved" and "delving"
+func (d *Detector) scanWordStem(lowerText string, tokens []token, w WordEntry, result *ScanResult) {
+	stem := Stem(strings.ToLower(w.Word))
+
+	for _, t := range tokens {
+		if t.stem != stem {
+			continue
+		}
+		pct := w.PctModels
+		if p, ok := w.Forms[t.text]; ok {
+			pct = p
+		}
+		addHit(result, w.Context, lowerText, tokens, t.start, t.end, Hit{
+			Type:      "word",
+			Rule:      w.Word,
+			Detail:    fmt.Sprintf("%.1f%% of models overuse this word", pct),
+			Severity:  w.Severity,
+			Weight:    pct / 100.0,
+			Family:    w.Word,
+			Offset:    t.start,
+			EndOffset: t.end,
+		})
+	}
+}
+
+// scanTrigram matches a phrase against whole tokens. Modes:
+//   - exact:   words adjacent and in order, separated only by whitespace, hyphens or apostrophes
+//   - ordered: words in order, other tokens allowed in between, within Window tokens
+//   - window:  words in any order within Window tokens
+//
+// The stopword-stripped trigrams from the paper ("voice barely whisper") rely on
+// the ordered default; full idioms in presets use exact.
+func (d *Detector) scanTrigram(lowerText string, tokens []token, t TrigramEntry, result *ScanResult) {
+	words := tokenRegex.FindAllString(strings.ToLower(t.Phrase), -1)
 	if len(words) < 2 {
 		return
 	}
 
-	// Simple substring search for the phrase
-	idx := 0
-	for {
-		pos := strings.Index(lowerText[idx:], words[0])
-		if pos == -1 {
-			break
-		}
-		absPos := idx + pos
+	window := t.Window
+	if window < len(words) {
+		window = len(words) + 3
+	}
 
-		// Check if remaining words appear nearby (within 60 chars)
-		window := 60
-		end := absPos + window
-		if end > len(lowerText) {
-			end = len(lowerText)
+	for i := 0; i < len(tokens); i++ {
+		var last int
+		switch t.Mode {
+		case "exact":
+			last = matchExact(lowerText, tokens, i, words)
+		case "window":
+			last = matchWindow(tokens, i, words, window)
+		default:
+			last = matchOrdered(tokens, i, words, window)
+		}
+		if last < 0 {
+			continue
 		}
-		snippet := lowerText[absPos:end]
 
-		allFound := true
-		for _, w := range words[1:] {
-			if !strings.Contains(snippet, w) {
-				allFound = false
-				break
+		start, end := tokens[i].start, tokens[last].end
+		addHit(result, t.Context, lowerText, tokens, start, end, Hit{
+			Type:      "trigram",
+			Rule:      t.Phrase,
+			Detail:    fmt.Sprintf("%.1f%% of models overuse this phrase", t.PctModels),
+			Severity:  t.Severity,
+			Weight:    t.PctModels / 100.0,
+			Family:    t.Phrase,
+			Offset:    start,
+			EndOffset: end,
+		})
+
+		// Matches never overlap; resume after the matched span
+		i = last
+	}
+}
+
+// matchExact returns the index of the last token when words occur verbatim
+// starting at tokens[i], or -1
+func matchExact(lowerText string, tokens []token, i int, words []string) int {
+	if i+len(words) > len(tokens) {
+		return -1
+	}
+	for k, w := range words {
+		tok := tokens[i+k]
+		if tok.text != w {
+			return -1
+		}
+		if k > 0 {
+			gap := lowerText[tokens[i+k-1].end:tok.start]
+			if strings.Trim(gap, " \t\r\n-'’") != "" {
+				return -1
 			}
 		}
+	}
+	return i + len(words) - 1
+}
 
-		if allFound {
-			line, col := posToLineCol(absPos, lineOffsets)
-			result.Hits = append(result.Hits, Hit{
-				Line:     line,
-				Column:   col,
-				Match:    t.Phrase,
-				Type:     "trigram",
-				Detail:   fmt.Sprintf("%.1f%% of models overuse this phrase", t.PctModels),
-				Severity: t.Severity,
-				Weight:   t.PctModels / 100.0,
-			})
+// matchOrdered returns the index of the last token when words occur in order
+// within window tokens starting at tokens[i], or -1
+func matchOrdered(tokens []token, i int, words []string, window int) int {
+	if !tokens[i].is(words[0]) {
+		return -1
+	}
+	limit := i + window
+	if limit > len(tokens) {
+		limit = len(tokens)
+	}
+	j := i
+	for _, w := range words[1:] {
+		j++
+		for j < limit && !tokens[j].is(w) {
+			j++
+		}
+		if j >= limit {
+			return -1
 		}
+	}
+	return j
+}
 
-		idx = absPos + len(words[0])
-		if idx >= len(lowerText) {
-			break
+// matchWindow returns the index of the last token needed when every word
+// occurs, in any order, within window tokens starting at tokens[i], or -1
+func matchWindow(tokens []token, i int, words []string, window int) int {
+	need := make(map[string]int, len(words))
+	for _, w := range words {
+		need[w]++
+	}
+	if !tokens[i].isAny(need) {
+		return -1
+	}
+	limit := i + window
+	if limit > len(tokens) {
+		limit = len(tokens)
+	}
+	remaining := len(words)
+	for j := i; j < limit; j++ {
+		for _, form := range tokens[j].forms() {
+			if need[form] > 0 {
+				need[form]--
+				remaining--
+				break
+			}
+		}
+		if remaining == 0 {
+			return j
 		}
 	}
+	return -1
 }
 
-func (d *Detector) scanPattern(lowerText string, p compiledPattern, lineOffsets []int, result *ScanResult) {
+func (d *Detector) scanPattern(lowerText string, p compiledPattern, result *ScanResult) {
 	matches := p.regex.FindAllStringIndex(lowerText, -1)
 
 	for _, m := range matches {
-		line, col := posToLineCol(m[0], lineOffsets)
-		matchText := lowerText[m[0]:m[1]]
-		if len(matchText) > 60 {
-			matchText = matchText[:60] + "..."
-		}
 		result.Hits = append(result.Hits, Hit{
-			Line:     line,
-			Column:   col,
-			Match:    matchText,
-			Type:     "pattern",
-			Detail:   fmt.Sprintf("%s (%.1fx overrepresented)", p.entry.Description, p.entry.OveruseRat),
-			Severity: p.entry.Severity,
-			Weight:   p.entry.OveruseRat / 10.0, // normalize to ~0-1 range
+			Type:      "pattern",
+			Rule:      p.entry.Name,
+			Detail:    fmt.Sprintf("%s (%.1fx overrepresented)", p.entry.Description, p.entry.OveruseRat),
+			Severity:  p.entry.Severity,
+			Weight:    p.entry.OveruseRat / 10.0, // normalize to ~0-1 range
+			Offset:    m[0],
+			EndOffset: m[1],
 		})
 	}
 }
 
+// addHit records a hit, or files it under Suppressed when the entry's
+// context rule rejects the surrounding text
+func addHit(result *ScanResult, rule *ContextRule, lowerText string, tokens []token, start, end int, hit Hit) {
+	if rule != nil {
+		if ok, reason := rule.check(lowerText, tokens, start, end); !ok {
+			hit.SuppressedBy = reason
+			result.Suppressed = append(result.Suppressed, hit)
+			return
+		}
+	}
+	result.Hits = append(result.Hits, hit)
+}
+
 func (d *Detector) calculateScore(result *ScanResult) float64 {
 	if len(result.Hits) == 0 || result.WordCount == 0 {
 		return 0
@@ -415,24 +621,52 @@ func (d *Detector) calculateScore(result *ScanResult) float64 {
 }
 
 // Helper functions
-func buildLineOffsets(text string) []int {
-	offsets := []int{0}
-	for i, ch := range text {
-		if ch == '\n' {
-			offsets = append(offsets, i+1)
+
+// token is a single word in the lowercased text with its byte span
+type token struct {
+	text  string
+	alt   string // "could" for the "couldn" in "couldn't"
+	stem  string
+	start int
+	end   int
+}
+
+var tokenRegex = regexp.MustCompile(`\w+`)
+
+func tokenize(lowerText string) []token {
+	matches := tokenRegex.FindAllStringIndex(lowerText, -1)
+	tokens := make([]token, 0, len(matches))
+	for _, m := range matches {
+		text := lowerText[m[0]:m[1]]
+		tok := token{text: text, stem: Stem(text), start: m[0], end: m[1]}
+		// Negative contractions split as "couldn" + "t"; let phrase words
+		// like "could" still match the verb
+		rest := lowerText[m[1]:]
+		if len(text) > 3 && strings.HasSuffix(text, "n") &&
+			(strings.HasPrefix(rest, "'t") || strings.HasPrefix(rest, "’t")) {
+			tok.alt = text[:len(text)-1]
 		}
+		tokens = append(tokens, tok)
 	}
-	return offsets
+	return tokens
+}
+
+func (t token) is(word string) bool {
+	return t.text == word || (t.alt != "" && t.alt == word)
 }
 
-func posToLineCol(pos int, lineOffsets []int) (int, int) {
-	line := 1
-	for i, offset := range lineOffsets {
-		if offset > pos {
-			break
+func (t token) isAny(words map[string]int) bool {
+	for _, form := range t.forms() {
+		if words[form] > 0 {
+			return true
 		}
-		line = i + 1
 	}
-	col := pos - lineOffsets[line-1] + 1
-	return line, col
+	return false
+}
+
+func (t token) forms() []string {
+	if t.alt == "" {
+		return []string{t.text}
+	}
+	return []string{t.text, t.alt}
 }
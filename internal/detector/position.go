@@ -0,0 +1,46 @@
+package detector
+
+import "sort"
+
+// lineIndex maps byte offsets in a text to line and column positions
+type lineIndex struct {
+	text    string
+	offsets []int // byte offset where each line starts
+}
+
+func newLineIndex(text string) *lineIndex {
+	offsets := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+	return &lineIndex{text: text, offsets: offsets}
+}
+
+// position returns the 1-based line plus rune, UTF-16 and byte columns of pos
+func (li *lineIndex) position(pos int) (line, col, utf16Col, byteCol int) {
+	// Last line starting at or before pos
+	i := sort.Search(len(li.offsets), func(i int) bool { return li.offsets[i] > pos }) - 1
+	if i < 0 {
+		i = 0
+	}
+	lineStart := li.offsets[i]
+
+	col, utf16Col = 1, 1
+	for _, r := range li.text[lineStart:pos] {
+		col++
+		if r >= 0x10000 {
+			utf16Col += 2
+		} else {
+			utf16Col++
+		}
+	}
+	return i + 1, col, utf16Col, pos - lineStart + 1
+}
+
+// locate fills in a hit's start and end positions from its byte offsets
+func (li *lineIndex) locate(h *Hit) {
+	h.Line, h.Column, h.UTF16Column, h.ByteColumn = li.position(h.Offset)
+	h.EndLine, h.EndColumn, h.EndUTF16Column, _ = li.position(h.EndOffset)
+}
@@ -0,0 +1,132 @@
+package detector
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	maxMatchRunes   = 60 // Hit.Match is cut beyond this
+	snippetRunes    = 40 // context kept on each side of a match
+	ellipsis        = "..."
+	snippetEllipsis = "…"
+)
+
+// Snippet is the original text around a hit, split so the match can be
+// highlighted. Context never crosses a line break.
+type Snippet struct {
+	Before string `json:"before"`
+	Match  string `json:"match"`
+	After  string `json:"after"`
+}
+
+// foldCase lowercases text without changing its byte length, so offsets found
+// in the folded text index the original too. Runes whose lowercase form has a
+// different UTF-8 width (e.g. 'İ') and invalid bytes are copied unchanged.
+func foldCase(text string) string {
+	ascii := true
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			ascii = false
+			break
+		}
+	}
+	if ascii {
+		return strings.ToLower(text)
+	}
+
+	var b strings.Builder
+	b.Grow(len(text))
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if r == utf8.RuneError && size == 1 {
+			b.WriteByte(text[i])
+			i++
+			continue
+		}
+		if lr := unicode.ToLower(r); utf8.RuneLen(lr) == size {
+			b.WriteRune(lr)
+		} else {
+			b.WriteString(text[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
+
+// truncateRunes cuts s to at most n runes, marking the cut with "..."
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	i := 0
+	for pos := range s {
+		if i == n {
+			return s[:pos] + ellipsis
+		}
+		i++
+	}
+	return s
+}
+
+// makeSnippet returns the match at text[start:end] with up to snippetRunes
+// of same-line context on either side. Whitespace runs are collapsed.
+func makeSnippet(text string, start, end int) *Snippet {
+	lineStart := strings.LastIndexByte(text[:start], '\n') + 1
+	before := text[lineStart:start]
+	if n := utf8.RuneCountInString(before); n > snippetRunes {
+		skip := n - snippetRunes
+		for pos := range before {
+			if skip == 0 {
+				before = snippetEllipsis + before[pos:]
+				break
+			}
+			skip--
+		}
+	}
+
+	after := text[end:]
+	if nl := strings.IndexByte(after, '\n'); nl >= 0 {
+		after = after[:nl]
+	}
+	after = truncateRunes(after, snippetRunes)
+	if strings.HasSuffix(after, ellipsis) {
+		after = strings.TrimSuffix(after, ellipsis) + snippetEllipsis
+	}
+
+	sn := &Snippet{
+		Before: strings.TrimLeft(collapseSpace(before), " "),
+		Match:  collapseSpace(truncateRunes(text[start:end], maxMatchRunes)),
+		After:  strings.TrimRight(collapseSpace(after), " "),
+	}
+
+	// Patterns often swallow a trailing space; keep it outside the highlight
+	if trimmed := strings.TrimRight(sn.Match, " "); trimmed != sn.Match && trimmed != "" {
+		sn.Match = trimmed
+		sn.After = " " + sn.After
+	}
+	return sn
+}
+
+// collapseSpace folds runs of whitespace (including newlines inside
+// multi-line matches) into single spaces
+func collapseSpace(s string) string {
+	if !strings.ContainsAny(s, " \t\r\n") {
+		return s
+	}
+	var b strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
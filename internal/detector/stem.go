@@ -0,0 +1,245 @@
+package detector
+
+// Porter stemmer (M.F. Porter, 1980). Used for "match": "stem" word entries so
+// one banlist entry covers its inflections: flicker, flickers, flickered and
+// flickering all reduce to "flicker". Only lowercase ASCII words are stemmed;
+// anything else is returned unchanged.
+
+// Stem returns the Porter stem of a lowercase English word
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+
+	s := &stemmer{b: []byte(word)}
+	s.step1a()
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+	return string(s.b)
+}
+
+type stemmer struct {
+	b []byte
+}
+
+// isCons reports whether b[i] is a consonant in the Porter sense
+func (s *stemmer) isCons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		if i == 0 {
+			return true
+		}
+		return !s.isCons(i - 1)
+	}
+	return true
+}
+
+// measure counts VC sequences in b[:n]
+func (s *stemmer) measure(n int) int {
+	m := 0
+	i := 0
+	for i < n && s.isCons(i) {
+		i++
+	}
+	for i < n {
+		for i < n && !s.isCons(i) {
+			i++
+		}
+		if i >= n {
+			break
+		}
+		for i < n && s.isCons(i) {
+			i++
+		}
+		m++
+	}
+	return m
+}
+
+// hasVowel reports whether b[:n] contains a vowel
+func (s *stemmer) hasVowel(n int) bool {
+	for i := 0; i < n; i++ {
+		if !s.isCons(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// doubleCons reports whether b[:n] ends in a double consonant
+func (s *stemmer) doubleCons(n int) bool {
+	if n < 2 || s.b[n-1] != s.b[n-2] {
+		return false
+	}
+	return s.isCons(n - 1)
+}
+
+// cvc reports whether b[:n] ends consonant-vowel-consonant where the final
+// consonant is not w, x or y
+func (s *stemmer) cvc(n int) bool {
+	if n < 3 || !s.isCons(n-1) || s.isCons(n-2) || !s.isCons(n-3) {
+		return false
+	}
+	switch s.b[n-1] {
+	case 'w', 'x', 'y':
+		return false
+	}
+	return true
+}
+
+func (s *stemmer) hasSuffix(suffix string) bool {
+	n := len(s.b)
+	if len(suffix) > n {
+		return false
+	}
+	return string(s.b[n-len(suffix):]) == suffix
+}
+
+// replace swaps suffix for repl when the remaining stem has measure > minM
+func (s *stemmer) replace(suffix, repl string, minM int) bool {
+	if !s.hasSuffix(suffix) {
+		return false
+	}
+	stem := len(s.b) - len(suffix)
+	if s.measure(stem) > minM {
+		s.b = append(s.b[:stem], repl...)
+	}
+	return true
+}
+
+func (s *stemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.b = s.b[:len(s.b)-2]
+	case s.hasSuffix("ies"):
+		s.b = s.b[:len(s.b)-2]
+	case s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		s.b = s.b[:len(s.b)-1]
+	}
+}
+
+func (s *stemmer) step1b() {
+	if s.hasSuffix("eed") {
+		if s.measure(len(s.b)-3) > 0 {
+			s.b = s.b[:len(s.b)-1]
+		}
+		return
+	}
+
+	var stem int
+	switch {
+	case s.hasSuffix("ed"):
+		stem = len(s.b) - 2
+	case s.hasSuffix("ing"):
+		stem = len(s.b) - 3
+	default:
+		return
+	}
+	if !s.hasVowel(stem) {
+		return
+	}
+	s.b = s.b[:stem]
+
+	switch {
+	case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+		s.b = append(s.b, 'e')
+	case s.doubleCons(len(s.b)):
+		switch s.b[len(s.b)-1] {
+		case 'l', 's', 'z':
+		default:
+			s.b = s.b[:len(s.b)-1]
+		}
+	case s.measure(len(s.b)) == 1 && s.cvc(len(s.b)):
+		s.b = append(s.b, 'e')
+	}
+}
+
+func (s *stemmer) step1c() {
+	n := len(s.b)
+	if s.b[n-1] == 'y' && s.hasVowel(n-1) {
+		s.b[n-1] = 'i'
+	}
+}
+
+var step2Suffixes = [][2]string{
+	{"ational", "ate"}, {"tional", "tion"}, {"enci", "ence"}, {"anci", "ance"},
+	{"izer", "ize"}, {"bli", "ble"}, {"alli", "al"}, {"entli", "ent"},
+	{"eli", "e"}, {"ousli", "ous"}, {"ization", "ize"}, {"ation", "ate"},
+	{"ator", "ate"}, {"alism", "al"}, {"iveness", "ive"}, {"fulness", "ful"},
+	{"ousness", "ous"}, {"aliti", "al"}, {"iviti", "ive"}, {"biliti", "ble"},
+	{"logi", "log"},
+}
+
+func (s *stemmer) step2() {
+	for _, r := range step2Suffixes {
+		if s.replace(r[0], r[1], 0) {
+			return
+		}
+	}
+}
+
+var step3Suffixes = [][2]string{
+	{"icate", "ic"}, {"ative", ""}, {"alize", "al"}, {"iciti", "ic"},
+	{"ical", "ic"}, {"ful", ""}, {"ness", ""},
+}
+
+func (s *stemmer) step3() {
+	for _, r := range step3Suffixes {
+		if s.replace(r[0], r[1], 0) {
+			return
+		}
+	}
+}
+
+var step4Suffixes = []string{
+	"al", "ance", "ence", "er", "ic", "able", "ible", "ant", "ement", "ment",
+	"ent", "ion", "ou", "ism", "ate", "iti", "ous", "ive", "ize",
+}
+
+func (s *stemmer) step4() {
+	// Longest suffix wins, so check "ement" before "ment" before "ent"
+	best := ""
+	for _, suf := range step4Suffixes {
+		if len(suf) > len(best) && s.hasSuffix(suf) {
+			best = suf
+		}
+	}
+	if best == "" {
+		return
+	}
+	stem := len(s.b) - len(best)
+	if best == "ion" {
+		if stem == 0 || (s.b[stem-1] != 's' && s.b[stem-1] != 't') {
+			return
+		}
+	}
+	if s.measure(stem) > 1 {
+		s.b = s.b[:stem]
+	}
+}
+
+func (s *stemmer) step5() {
+	n := len(s.b)
+	if s.b[n-1] == 'e' {
+		m := s.measure(n - 1)
+		if m > 1 || (m == 1 && !s.cvc(n-1)) {
+			s.b = s.b[:n-1]
+		}
+	}
+	n = len(s.b)
+	if s.b[n-1] == 'l' && s.doubleCons(n) && s.measure(n) > 1 {
+		s.b = s.b[:n-1]
+	}
+}
@@ -0,0 +1,91 @@
+package history
+
+import (
+	"math"
+	"sort"
+)
+
+// Page change statuses
+const (
+	Regressed = "regressed"
+	Improved  = "improved"
+	Unchanged = "unchanged"
+	Added     = "new"
+	Removed   = "removed"
+)
+
+// PageChange is how one page moved between two runs
+type PageChange struct {
+	Path         string  `json:"path"`
+	Status       string  `json:"status"`
+	Before       float64 `json:"before"`
+	After        float64 `json:"after"`
+	Delta        float64 `json:"delta"`
+	BeforeRating string  `json:"before_rating,omitempty"`
+	AfterRating  string  `json:"after_rating,omitempty"`
+}
+
+var ratingRank = map[string]int{"clean": 0, "moderate": 1, "heavy": 2}
+
+// ComparePages matches pages by path and classifies each one. A page
+// regresses when its score rises by at least threshold or its rating gets
+// worse, and improves on the reverse; with a threshold of 0, any change in
+// score counts. Results are ordered by status, then by the size of the change.
+func ComparePages(before, after []PageScore, threshold float64) []PageChange {
+	prev := make(map[string]PageScore, len(before))
+	for _, p := range before {
+		prev[p.Path] = p
+	}
+
+	var changes []PageChange
+	seen := make(map[string]bool, len(after))
+	for _, p := range after {
+		seen[p.Path] = true
+		old, ok := prev[p.Path]
+		if !ok {
+			changes = append(changes, PageChange{
+				Path: p.Path, Status: Added, After: p.Score, Delta: p.Score, AfterRating: p.Rating,
+			})
+			continue
+		}
+
+		c := PageChange{
+			Path:         p.Path,
+			Status:       Unchanged,
+			Before:       old.Score,
+			After:        p.Score,
+			Delta:        p.Score - old.Score,
+			BeforeRating: old.Rating,
+			AfterRating:  p.Rating,
+		}
+		worse := ratingRank[p.Rating] > ratingRank[old.Rating]
+		better := ratingRank[p.Rating] < ratingRank[old.Rating]
+		switch {
+		case (c.Delta > 0 && c.Delta >= threshold) || worse:
+			c.Status = Regressed
+		case (c.Delta < 0 && -c.Delta >= threshold) || better:
+			c.Status = Improved
+		}
+		changes = append(changes, c)
+	}
+
+	for _, p := range before {
+		if !seen[p.Path] {
+			changes = append(changes, PageChange{
+				Path: p.Path, Status: Removed, Before: p.Score, Delta: -p.Score, BeforeRating: p.Rating,
+			})
+		}
+	}
+
+	order := map[string]int{Regressed: 0, Added: 1, Improved: 2, Removed: 3, Unchanged: 4}
+	sort.SliceStable(changes, func(i, j int) bool {
+		if order[changes[i].Status] != order[changes[j].Status] {
+			return order[changes[i].Status] < order[changes[j].Status]
+		}
+		if math.Abs(changes[i].Delta) != math.Abs(changes[j].Delta) {
+			return math.Abs(changes[i].Delta) > math.Abs(changes[j].Delta)
+		}
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
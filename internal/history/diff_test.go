@@ -0,0 +1,61 @@
+package history
+
+import "testing"
+
+func TestComparePages(t *testing.T) {
+	before := []PageScore{
+		{Path: "/same", Score: 10, Rating: "clean"},
+		{Path: "/up", Score: 10, Rating: "clean"},
+		{Path: "/down", Score: 20, Rating: "clean"},
+		{Path: "/worse", Score: 19, Rating: "clean"},
+		{Path: "/gone", Score: 5, Rating: "clean"},
+	}
+	after := []PageScore{
+		{Path: "/same", Score: 10, Rating: "clean"},
+		{Path: "/up", Score: 13, Rating: "clean"},
+		{Path: "/down", Score: 17, Rating: "clean"},
+		{Path: "/worse", Score: 21, Rating: "moderate"},
+		{Path: "/new", Score: 8, Rating: "clean"},
+	}
+	tests := []struct {
+		threshold float64
+		want      map[string]string
+	}{
+		{0, map[string]string{
+			"/same": Unchanged, "/up": Regressed, "/down": Improved,
+			"/worse": Regressed, "/new": Added, "/gone": Removed,
+		}},
+		{5, map[string]string{
+			"/same": Unchanged, "/up": Unchanged, "/down": Unchanged,
+			"/worse": Regressed, "/new": Added, "/gone": Removed,
+		}},
+		{3, map[string]string{
+			"/same": Unchanged, "/up": Regressed, "/down": Improved,
+			"/worse": Regressed, "/new": Added, "/gone": Removed,
+		}},
+	}
+	for _, tt := range tests {
+		changes := ComparePages(before, after, tt.threshold)
+		if len(changes) != len(tt.want) {
+			t.Errorf("threshold %v: %d changes, want %d", tt.threshold, len(changes), len(tt.want))
+		}
+		for _, c := range changes {
+			if c.Status != tt.want[c.Path] {
+				t.Errorf("threshold %v: %s is %s, want %s", tt.threshold, c.Path, c.Status, tt.want[c.Path])
+			}
+		}
+	}
+}
+
+func TestComparePagesOrder(t *testing.T) {
+	before := []PageScore{{Path: "/a", Score: 10}, {Path: "/b", Score: 10}, {Path: "/c", Score: 10}}
+	after := []PageScore{{Path: "/a", Score: 16}, {Path: "/b", Score: 30}, {Path: "/c", Score: 10}}
+	changes := ComparePages(before, after, 5)
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Path)
+	}
+	if len(got) != 3 || got[0] != "/b" || got[1] != "/a" || got[2] != "/c" {
+		t.Errorf("order = %q, want [/b /a /c]", got)
+	}
+}
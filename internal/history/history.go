@@ -0,0 +1,145 @@
+// Package history keeps an append-only log of report runs so slop scores can
+// be followed over time.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+const runsFile = "runs.jsonl"
+
+// PageScore is one page's result within a run
+type PageScore struct {
+	Path   string  `json:"path"`
+	Score  float64 `json:"score"`
+	Rating string  `json:"rating"`
+	Hits   int     `json:"hits"`
+	Words  int     `json:"words"`
+}
+
+// Run is a single report run as recorded in the store
+type Run struct {
+	RunAt        time.Time   `json:"run_at"`
+	Source       string      `json:"source"`
+	Mode         string      `json:"mode"`
+	Version      string      `json:"version"`
+	RuleSetHash  string      `json:"rule_set_hash"`
+	AverageScore float64     `json:"average_score"`
+	TotalWords   int         `json:"total_words"`
+	TotalHits    int         `json:"total_hits"`
+	Clean        int         `json:"clean"`
+	Moderate     int         `json:"moderate"`
+	Heavy        int         `json:"heavy"`
+	Pages        []PageScore `json:"pages"`
+	// Partial runs didn't score the whole site: the crawl was interrupted,
+	// or --since left unchanged pages out. They are kept out of page diffs.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// Store is a directory holding runs.jsonl, one Run per line
+type Store struct {
+	dir string
+}
+
+// DefaultDir returns $XDG_STATE_HOME/slopsquid, falling back to
+// ~/.local/state/slopsquid
+func DefaultDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "slopsquid"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("locating home directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "slopsquid"), nil
+}
+
+// Open returns a store rooted at dir, creating the directory if needed
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Path returns the file runs are appended to
+func (s *Store) Path() string {
+	return filepath.Join(s.dir, runsFile)
+}
+
+// Append records a run. Earlier runs are never rewritten.
+func (s *Store) Append(run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.Path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("writing history: %w", err)
+	}
+	return nil
+}
+
+// Runs returns the recorded runs for source, oldest first. An empty source
+// returns every run. Lines that fail to parse are skipped so one bad write
+// does not hide the rest of the history.
+func (s *Store) Runs(source string) ([]Run, error) {
+	f, err := os.Open(s.Path())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("opening history: %w", err)
+	}
+	defer f.Close()
+
+	var runs []Run
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for sc.Scan() {
+		var run Run
+		if err := json.Unmarshal(sc.Bytes(), &run); err != nil {
+			continue
+		}
+		if source == "" || run.Source == source {
+			runs = append(runs, run)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].RunAt.Before(runs[j].RunAt)
+	})
+	return runs, nil
+}
+
+// Sources lists every source with at least one run, in order of first run
+func (s *Store) Sources() ([]string, error) {
+	runs, err := s.Runs("")
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var sources []string
+	for _, r := range runs {
+		if !seen[r.Source] {
+			seen[r.Source] = true
+			sources = append(sources, r.Source)
+		}
+	}
+	return sources, nil
+}
@@ -0,0 +1,129 @@
+package scanner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+)
+
+// feed is the part of an RSS 2.0, RSS 1.0 or Atom document that is read
+type feed struct {
+	XMLName xml.Name
+	// RSS 2.0 items are in the channel; RSS 1.0 items sit beside it
+	Channel struct {
+		Items []feedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []feedItem  `xml:"item"`
+	Entries []feedEntry `xml:"entry"`
+}
+
+type feedItem struct {
+	Title       string `xml:"title"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+type feedEntry struct {
+	Title   feedText `xml:"title"`
+	Summary feedText `xml:"summary"`
+	Content feedText `xml:"content"`
+}
+
+// feedText is an Atom text construct: escaped HTML, or inline XHTML
+type feedText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+func (t feedText) html() string {
+	if t.Type == "xhtml" {
+		return t.Inner
+	}
+	return t.Text
+}
+
+// newFeedDecoder returns a decoder as forgiving as feeds in the wild need:
+// they use HTML entities and unclosed tags
+func newFeedDecoder(content string) *xml.Decoder {
+	dec := xml.NewDecoder(strings.NewReader(content))
+	dec.Strict = false
+	dec.AutoClose = xml.HTMLAutoClose
+	dec.Entity = xml.HTMLEntity
+	dec.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		switch strings.ToLower(charset) {
+		case "utf-8", "us-ascii", "ascii":
+			return input, nil
+		}
+		return nil, fmt.Errorf("unsupported charset %s", charset)
+	}
+	return dec
+}
+
+// isFeedRoot reports whether a root element names an RSS 2.0, RSS 1.0 or
+// Atom document
+func isFeedRoot(name string) bool {
+	return name == "rss" || name == "RDF" || name == "feed"
+}
+
+// IsFeed reports whether content is an RSS or Atom document, judging by its
+// root element. Other XML, such as a sitemap, is not read as a feed.
+func IsFeed(content string) bool {
+	dec := newFeedDecoder(content)
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return false
+		}
+		if el, ok := tok.(xml.StartElement); ok {
+			return isFeedRoot(el.Name.Local)
+		}
+	}
+}
+
+// extractFeedText returns the titles and bodies of a feed's items. ok is
+// false when content isn't an RSS or Atom feed.
+func (s *Scanner) extractFeedText(content string) (text string, ok bool) {
+	var f feed
+	if err := newFeedDecoder(content).Decode(&f); err != nil {
+		return "", false
+	}
+	if !isFeedRoot(f.XMLName.Local) {
+		return "", false
+	}
+
+	var parts []string
+	add := func(title, body string) {
+		for _, t := range []string{title, body} {
+			if t = s.feedHTMLText(t); t != "" {
+				parts = append(parts, t)
+			}
+		}
+	}
+	for _, it := range append(f.Channel.Items, f.Items...) {
+		// The full content, when present, repeats the description
+		body := it.Content
+		if strings.TrimSpace(body) == "" {
+			body = it.Description
+		}
+		add(it.Title, body)
+	}
+	for _, e := range f.Entries {
+		body := e.Content.html()
+		if strings.TrimSpace(body) == "" {
+			body = e.Summary.html()
+		}
+		add(e.Title.html(), body)
+	}
+	return strings.Join(parts, "\n"), true
+}
+
+// feedHTMLText reads an item's HTML the way HTML files are read
+func (s *Scanner) feedHTMLText(body string) string {
+	if strings.ContainsAny(body, "<&") {
+		body = html.UnescapeString(s.extractHTMLText(body))
+	}
+	return strings.TrimSpace(body)
+}
@@ -0,0 +1,27 @@
+package scanner
+
+import "testing"
+
+func TestExtractXML(t *testing.T) {
+	const rss = `<?xml version="1.0"?><rss version="2.0"><channel><title>Blog</title>` +
+		`<item><title>Post</title><description>&lt;p&gt;Item body&lt;/p&gt;</description></item></channel></rss>`
+	const sitemap = `<?xml version="1.0"?><urlset><url><loc>https://example.com/</loc></url></urlset>`
+	const config = `<config><note>Keep this text</note></config>`
+	tests := []struct {
+		fileType, content, want string
+	}{
+		{"xml", rss, "Post\nItem body"},
+		{"feed", rss, "Post\nItem body"},
+		{"xml", sitemap, sitemap},
+		{"xml", config, config},
+		{"xml", "not xml at all", "not xml at all"},
+	}
+	for _, tt := range tests {
+		if got := ExtractText(tt.fileType, tt.content); got != tt.want {
+			t.Errorf("ExtractText(%s, %.30q) = %q, want %q", tt.fileType, tt.content, got, tt.want)
+		}
+	}
+	if !IsFeed(`<feed xmlns="http://www.w3.org/2005/Atom"></feed>`) || IsFeed(sitemap) {
+		t.Error("IsFeed should go by the root element")
+	}
+}
@@ -0,0 +1,156 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PathFilter applies a scanner's discovery rules beneath one root directory:
+// excluded directory names, extensions, include/exclude globs and ignore
+// files. Ignore files are read once per directory, as directories are first
+// asked about.
+type PathFilter struct {
+	s       *Scanner
+	root    string // as given, for relative paths
+	absRoot string // for ignore rules, which need real ancestors
+
+	mu    sync.Mutex
+	rules map[string][]ignoreRule // directory (relative to root) → rules for its entries
+}
+
+// NewFilter returns the filter a directory scan of root would use
+func (s *Scanner) NewFilter(root string) (*PathFilter, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	return &PathFilter{
+		s:       s,
+		root:    root,
+		absRoot: abs,
+		rules:   make(map[string][]ignoreRule),
+	}, nil
+}
+
+// rel returns path relative to the root, or "" if it lies outside it
+func (f *PathFilter) rel(path string) string {
+	rel, err := filepath.Rel(f.root, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return rel
+}
+
+// rulesFor returns the ignore rules that apply to entries of dir, inherited
+// rules first so the directory's own files take precedence
+func (f *PathFilter) rulesFor(dir string) []ignoreRule {
+	if f.s.options.NoIgnore {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.rulesLocked(dir)
+}
+
+func (f *PathFilter) rulesLocked(dir string) []ignoreRule {
+	if rules, ok := f.rules[dir]; ok {
+		return rules
+	}
+
+	var inherited []ignoreRule
+	if dir == "." {
+		inherited = repoIgnoreRules(f.absRoot)
+	} else {
+		inherited = f.rulesLocked(filepath.Dir(dir))
+	}
+
+	abs := filepath.Join(f.absRoot, dir)
+	rules := inherited[:len(inherited):len(inherited)]
+	for _, name := range IgnoreFiles {
+		rules = append(rules, parseIgnoreFile(filepath.Join(abs, name), abs)...)
+	}
+	f.rules[dir] = rules
+	return rules
+}
+
+func matchAny(globs []*Glob, rel string) bool {
+	for _, g := range globs {
+		if g.Match(rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// SkipsDir reports whether the directory at path is left out of the scan.
+// The root itself is never skipped.
+func (f *PathFilter) SkipsDir(path string) bool {
+	rel := f.rel(path)
+	if rel == "" {
+		return false
+	}
+	if f.s.shouldExcludeDir(filepath.Base(path)) {
+		return true
+	}
+	slash := filepath.ToSlash(rel)
+	if matchAny(f.s.exclude, slash) {
+		return true
+	}
+	return ignored(f.rulesFor(filepath.Dir(rel)), filepath.Join(f.absRoot, rel), true)
+}
+
+// Accepts reports whether the file at path would be scanned. It does not
+// check the file's parent directories; a walk that honors SkipsDir never
+// reaches files inside skipped ones.
+func (f *PathFilter) Accepts(path string) bool {
+	if f.s.shouldExcludeFile(path) || !f.s.hasValidExtension(path) {
+		return false
+	}
+	rel := f.rel(path)
+	if rel == "" {
+		return false
+	}
+	slash := filepath.ToSlash(rel)
+	if matchAny(f.s.exclude, slash) {
+		return false
+	}
+	if len(f.s.include) > 0 && !matchAny(f.s.include, slash) {
+		return false
+	}
+	return !ignored(f.rulesFor(filepath.Dir(rel)), filepath.Join(f.absRoot, rel), false)
+}
+
+// Allows is Accepts for a file found on its own rather than by a walk: its
+// parent directories below the root must not be skipped either
+func (f *PathFilter) Allows(path string) bool {
+	if !f.Accepts(path) {
+		return false
+	}
+	for dir := filepath.Dir(path); f.rel(dir) != ""; dir = filepath.Dir(dir) {
+		if f.SkipsDir(dir) {
+			return false
+		}
+	}
+	return true
+}
+
+// ScanFile reads and extracts one file beneath the root, applying the same
+// filters as a directory scan. It returns nil for files the scan would skip.
+func (f *PathFilter) ScanFile(path string) (*FileInfo, error) {
+	if !f.Allows(path) {
+		return nil, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+	return f.s.scanFile(path, info)
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPathFilter(t *testing.T) {
+	root := t.TempDir()
+	// A .git directory stops ignore files above the temp dir from applying
+	writeTree(t, root, map[string]string{
+		".git/HEAD":                  "ref: refs/heads/main\n",
+		".gitignore":                 "drafts/\n*.tmp.md\n",
+		".slopsquidignore":           "!keep.tmp.md\n",
+		"docs/.slopsquidignore":      "private.md\n",
+		"docs/a.md":                  "a",
+		"docs/private.md":            "p",
+		"docs/deep/b.md":             "b",
+		"docs/generated/c.md":        "c",
+		"drafts/d.md":                "d",
+		"notes/e.md":                 "e",
+		"scratch.tmp.md":             "s",
+		"keep.tmp.md":                "k",
+		"node_modules/pkg/readme.md": "r",
+		"image.png":                  "i",
+	})
+
+	tests := []struct {
+		name    string
+		options ScanOptions
+		want    map[string]bool
+	}{
+		{
+			name: "defaults",
+			want: map[string]bool{
+				"docs/a.md": true, "docs/private.md": false, "docs/deep/b.md": true,
+				"docs/generated/c.md": true, "drafts/d.md": false, "notes/e.md": true,
+				"scratch.tmp.md": false, "keep.tmp.md": true,
+				"node_modules/pkg/readme.md": false, "image.png": false,
+			},
+		},
+		{
+			name:    "include and exclude globs",
+			options: ScanOptions{Include: []string{"docs/**"}, Exclude: []string{"**/generated/**"}},
+			want: map[string]bool{
+				"docs/a.md": true, "docs/deep/b.md": true, "docs/generated/c.md": false,
+				"notes/e.md": false, "keep.tmp.md": false,
+			},
+		},
+		{
+			name:    "no ignore files",
+			options: ScanOptions{NoIgnore: true},
+			want: map[string]bool{
+				"docs/private.md": true, "drafts/d.md": true, "scratch.tmp.md": true,
+				"node_modules/pkg/readme.md": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		f, err := NewScanner(tt.options).NewFilter(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, want := range tt.want {
+			path := filepath.Join(root, filepath.FromSlash(name))
+			if got := f.Allows(path); got != want {
+				t.Errorf("%s: Allows(%s) = %v, want %v", tt.name, name, got, want)
+			}
+			file, err := f.ScanFile(path)
+			if err != nil {
+				t.Errorf("%s: ScanFile(%s): %v", tt.name, name, err)
+			}
+			if (file != nil) != want {
+				t.Errorf("%s: ScanFile(%s) scanned = %v, want %v", tt.name, name, file != nil, want)
+			}
+		}
+	}
+}
+
+func TestPathFilterSkipsDir(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".git/HEAD":  "ref: refs/heads/main\n",
+		".gitignore": "drafts/\n",
+	})
+	f, err := NewScanner(ScanOptions{Exclude: []string{"archive"}}).NewFilter(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		dir  string
+		want bool
+	}{
+		{".", false}, // the root is never skipped
+		{"docs", false},
+		{"drafts", true},
+		{"archive", true},
+		{".git", true},
+		{"vendor", true},
+	}
+	for _, tt := range tests {
+		if got := f.SkipsDir(filepath.Join(root, tt.dir)); got != tt.want {
+			t.Errorf("SkipsDir(%s) = %v, want %v", tt.dir, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package scanner
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Glob is a compiled doublestar pattern. Paths are slash-separated and
+// relative to the scan root. "*", "?" and "[...]" match within one path
+// segment, "**" matches any number of segments, and "{a,b}" alternates.
+// A pattern with no slash matches a file or directory name at any depth.
+type Glob struct {
+	pattern string
+	alts    [][]string // brace-expanded alternatives, split into segments
+}
+
+// CompileGlob validates pattern and prepares it for matching
+func CompileGlob(pattern string) (*Glob, error) {
+	p := strings.TrimPrefix(strings.TrimSpace(pattern), "./")
+	anchored := strings.HasPrefix(p, "/")
+	p = strings.Trim(p, "/")
+	if !anchored && !strings.Contains(p, "/") {
+		p = "**/" + p
+	}
+
+	g := &Glob{pattern: pattern}
+	for _, alt := range expandBraces(p) {
+		segs := strings.Split(alt, "/")
+		for _, seg := range segs {
+			if seg == "**" {
+				continue
+			}
+			if _, err := path.Match(seg, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+			}
+		}
+		g.alts = append(g.alts, segs)
+	}
+	return g, nil
+}
+
+// String returns the pattern as written
+func (g *Glob) String() string { return g.pattern }
+
+// Match reports whether the slash-separated relative path matches
+func (g *Glob) Match(rel string) bool {
+	segs := strings.Split(strings.Trim(rel, "/"), "/")
+	for _, alt := range g.alts {
+		if matchSegments(alt, segs) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments matches pattern segments against path segments, letting
+// "**" absorb zero or more path segments
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return true
+			}
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat, segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+// expandBraces turns "a.{md,txt}" into "a.md" and "a.txt", expanding the
+// first group and recursing for the rest. Unbalanced braces stay literal.
+func expandBraces(p string) []string {
+	open := -1
+	depth := 0
+	for i := 0; i < len(p); i++ {
+		switch p[i] {
+		case '\\':
+			i++
+		case '{':
+			if depth == 0 {
+				open = i
+			}
+			depth++
+		case '}':
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth != 0 {
+				continue
+			}
+			var out []string
+			for _, choice := range splitTopLevel(p[open+1 : i]) {
+				out = append(out, expandBraces(p[:open]+choice+p[i+1:])...)
+			}
+			return out
+		}
+	}
+	return []string{p}
+}
+
+// splitTopLevel splits a brace body on commas that are not nested in braces
+func splitTopLevel(body string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, body[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, body[start:])
+}
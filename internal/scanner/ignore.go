@@ -0,0 +1,125 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IgnoreFiles are read from every directory a scan walks. Rules in
+// .slopsquidignore apply after .gitignore in the same directory, so they can
+// re-include files git ignores.
+var IgnoreFiles = []string{".gitignore", ".slopsquidignore"}
+
+// ignoreRule is one line of a gitignore-style file
+type ignoreRule struct {
+	base    string   // directory holding the ignore file
+	segs    []string // pattern split on "/"
+	negate  bool     // "!pattern" re-includes
+	dirOnly bool     // "pattern/" matches directories only
+}
+
+// parseIgnoreFile reads the rules in path, which apply beneath base. A
+// missing file has no rules.
+func parseIgnoreFile(path, base string) []ignoreRule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if r, ok := parseIgnoreLine(sc.Text(), base); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseIgnoreLine follows gitignore(5): "#" comments, "!" negation, a
+// trailing "/" for directories, and a leading or middle "/" to anchor the
+// pattern to base. Other patterns match a name at any depth.
+func parseIgnoreLine(line, base string) (ignoreRule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are dropped unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	r := ignoreRule{base: base}
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	r.segs = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return r, true
+}
+
+// match reports whether the rule applies to path
+func (r ignoreRule) match(path string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return matchSegments(r.segs, strings.Split(filepath.ToSlash(rel), "/"))
+}
+
+// ignored applies rules in order; the last one to match decides
+func ignored(rules []ignoreRule, path string, isDir bool) bool {
+	result := false
+	for _, r := range rules {
+		if r.match(path, isDir) {
+			result = !r.negate
+		}
+	}
+	return result
+}
+
+// repoIgnoreRules collects the rules that apply to dir before its own ignore
+// files are read: .git/info/exclude, then the ignore files of every ancestor
+// up to the enclosing repository root. Outside a repository there are none.
+func repoIgnoreRules(dir string) []ignoreRule {
+	var chain []string // ancestors of dir, nearest first
+	root := dir
+	for {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return nil
+		}
+		root = parent
+		chain = append(chain, root)
+	}
+
+	rules := parseIgnoreFile(filepath.Join(root, ".git", "info", "exclude"), root)
+	for i := len(chain) - 1; i >= 0; i-- {
+		for _, name := range IgnoreFiles {
+			rules = append(rules, parseIgnoreFile(filepath.Join(chain[i], name), chain[i])...)
+		}
+	}
+	return rules
+}
@@ -3,6 +3,7 @@ package scanner
 import (
 	"fmt"
 	"io/fs"
+	"mime"
 	"os"
 	"path/filepath"
 	"strings"
@@ -26,11 +27,22 @@ type ScanOptions struct {
 	ExcludeDirs  []string
 	ExcludeFiles []string
 	FollowLinks  bool
+
+	// Include and Exclude are doublestar globs matched against paths
+	// relative to each directory target. When Include is set, a file must
+	// match one of them; Exclude skips matching files and directories.
+	Include []string
+	Exclude []string
+	// NoIgnore turns off .gitignore and .slopsquidignore handling
+	NoIgnore bool
 }
 
 // Scanner handles file discovery and content extraction
 type Scanner struct {
 	options ScanOptions
+	include []*Glob
+	exclude []*Glob
+	err     error // first invalid glob, reported by ScanTargets
 }
 
 // NewScanner creates a new file scanner with the given options
@@ -43,7 +55,7 @@ func NewScanner(options ScanOptions) *Scanner {
 		options.Extensions = []string{
 			".md", ".markdown", ".txt", ".text",
 			".rst", ".adoc", ".asciidoc",
-			".html", ".htm", ".xml",
+			".html", ".htm", ".xhtml", ".xml", ".rss", ".atom",
 		}
 	}
 
@@ -55,11 +67,32 @@ func NewScanner(options ScanOptions) *Scanner {
 		}
 	}
 
-	return &Scanner{options: options}
+	s := &Scanner{options: options}
+	s.include, s.err = compileGlobs(options.Include)
+	if s.err == nil {
+		s.exclude, s.err = compileGlobs(options.Exclude)
+	}
+	return s
+}
+
+func compileGlobs(patterns []string) ([]*Glob, error) {
+	var globs []*Glob
+	for _, p := range patterns {
+		g, err := CompileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		globs = append(globs, g)
+	}
+	return globs, nil
 }
 
 // ScanTargets processes a list of file or directory targets
 func (s *Scanner) ScanTargets(targets []string) ([]*FileInfo, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+
 	var allFiles []*FileInfo
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -111,19 +144,26 @@ func (s *Scanner) scanTarget(target string) ([]*FileInfo, error) {
 // scanDirectory recursively scans a directory for files
 func (s *Scanner) scanDirectory(dirPath string) ([]*FileInfo, error) {
 	var files []*FileInfo
+	filter, err := s.NewFilter(dirPath)
+	if err != nil {
+		return nil, err
+	}
 
-	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+	err = filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip excluded directories
+		// Skip excluded and ignored directories
 		if d.IsDir() {
-			if s.shouldExcludeDir(d.Name()) {
+			if path != dirPath && filter.SkipsDir(path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
+		if !filter.Accepts(path) {
+			return nil
+		}
 
 		// Process files
 		info, err := d.Info()
@@ -165,7 +205,7 @@ func (s *Scanner) scanFile(filePath string, info os.FileInfo) (*FileInfo, error)
 		return &FileInfo{
 			Path:  filePath,
 			Size:  info.Size(),
-			Type:  s.getFileType(filePath),
+			Type:  FileType(filePath),
 			Error: fmt.Sprintf("file too large: %d bytes", info.Size()),
 		}, nil
 	}
@@ -176,7 +216,7 @@ func (s *Scanner) scanFile(filePath string, info os.FileInfo) (*FileInfo, error)
 		return &FileInfo{
 			Path:  filePath,
 			Size:  info.Size(),
-			Type:  s.getFileType(filePath),
+			Type:  FileType(filePath),
 			Error: fmt.Sprintf("failed to read file: %v", err),
 		}, nil
 	}
@@ -187,7 +227,7 @@ func (s *Scanner) scanFile(filePath string, info os.FileInfo) (*FileInfo, error)
 		return &FileInfo{
 			Path:  filePath,
 			Size:  info.Size(),
-			Type:  s.getFileType(filePath),
+			Type:  FileType(filePath),
 			Error: fmt.Sprintf("failed to extract text: %v", err),
 		}, nil
 	}
@@ -195,27 +235,47 @@ func (s *Scanner) scanFile(filePath string, info os.FileInfo) (*FileInfo, error)
 	return &FileInfo{
 		Path:    filePath,
 		Size:    info.Size(),
-		Type:    s.getFileType(filePath),
+		Type:    FileType(filePath),
 		Content: textContent,
 	}, nil
 }
 
 // extractTextContent extracts readable text from different file formats
 func (s *Scanner) extractTextContent(filePath string, content []byte) (string, error) {
-	ext := strings.ToLower(filepath.Ext(filePath))
+	return s.extractText(FileType(filePath), string(content)), nil
+}
 
-	switch ext {
-	case ".md", ".markdown":
-		return s.extractMarkdownText(string(content)), nil
-	case ".html", ".htm":
-		return s.extractHTMLText(string(content)), nil
-	case ".rst":
-		return s.extractRestructuredText(string(content)), nil
-	case ".adoc", ".asciidoc":
-		return s.extractAsciidocText(string(content)), nil
+// ExtractText returns the readable text of content of the given file type,
+// as named by FileType or TypeForContentType. The crawler uses it for pages
+// that aren't HTML, so they read the same as local files.
+func ExtractText(fileType, content string) string {
+	// The extractors don't depend on the scan options
+	var s Scanner
+	return s.extractText(fileType, content)
+}
+
+func (s *Scanner) extractText(fileType, content string) string {
+	switch fileType {
+	case "markdown":
+		return s.extractMarkdownText(content)
+	case "html", "xhtml":
+		return s.extractHTMLText(content)
+	case "restructuredtext":
+		return s.extractRestructuredText(content)
+	case "asciidoc":
+		return s.extractAsciidocText(content)
+	case "feed", "xml":
+		// Only RSS and Atom roots are read item by item. Other XML is
+		// scanned as-is, as it always was.
+		if IsFeed(content) {
+			if text, ok := s.extractFeedText(content); ok {
+				return text
+			}
+		}
+		return content
 	default:
 		// For plain text and other formats, return as-is
-		return string(content), nil
+		return content
 	}
 }
 
@@ -376,13 +436,18 @@ func (s *Scanner) hasValidExtension(filePath string) bool {
 	return false
 }
 
-func (s *Scanner) getFileType(filePath string) string {
+// FileType names the format of a file from its extension
+func FileType(filePath string) string {
 	ext := strings.ToLower(filepath.Ext(filePath))
 	switch ext {
 	case ".md", ".markdown":
 		return "markdown"
 	case ".html", ".htm":
 		return "html"
+	case ".xhtml":
+		return "xhtml"
+	case ".rss", ".atom":
+		return "feed"
 	case ".rst":
 		return "restructuredtext"
 	case ".adoc", ".asciidoc":
@@ -396,6 +461,34 @@ func (s *Scanner) getFileType(filePath string) string {
 	}
 }
 
+// TypeForContentType names the format of a document served with the given
+// Content-Type, or returns "" when it isn't a text format the scanner reads
+func TypeForContentType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "text/html":
+		return "html"
+	case "application/xhtml+xml":
+		return "xhtml"
+	case "text/markdown", "text/x-markdown":
+		return "markdown"
+	case "text/x-rst", "text/prs.fallenstein.rst":
+		return "restructuredtext"
+	case "text/asciidoc", "text/x-asciidoc":
+		return "asciidoc"
+	case "application/rss+xml", "application/atom+xml", "application/rdf+xml":
+		return "feed"
+	case "application/xml", "text/xml":
+		return "xml"
+	case "text/plain":
+		return "text"
+	}
+	return ""
+}
+
 func (s *Scanner) extractLinkText(line string) string {
 	// Simple markdown link extraction: [text](url) -> text
 	for {
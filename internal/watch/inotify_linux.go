@@ -0,0 +1,136 @@
+//go:build linux
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const watchMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+	syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+
+// inotify watches every directory in the tree and reports file events
+type inotify struct {
+	root   string
+	filter Filter
+	fd     int
+	file   *os.File
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor → directory
+}
+
+func newNative(root string, filter Filter) (backend, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	// A non-blocking descriptor lets the runtime poller park reads, and
+	// closing the file wakes a pending read on shutdown. The raw fd is kept
+	// because File.Fd would switch it back to blocking mode.
+	in := &inotify{
+		root:   root,
+		filter: filter,
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		dirs:   make(map[int32]string),
+	}
+	if err := in.addTree(root, nil); err != nil {
+		in.file.Close()
+		return nil, err
+	}
+	return in, nil
+}
+
+func (in *inotify) name() string { return "inotify" }
+
+// addTree watches dir and its subdirectories, passing any files already
+// inside to found (used when a directory appears after startup)
+func (in *inotify) addTree(dir string, found func(path string)) error {
+	return walk(dir, in.filter, func(d string) error {
+		wd, err := syscall.InotifyAddWatch(in.fd, d, watchMask)
+		if err != nil {
+			if d == dir {
+				return err
+			}
+			// A subdirectory we can't watch (permissions, limits) is skipped
+			return nil
+		}
+		in.mu.Lock()
+		in.dirs[int32(wd)] = d
+		in.mu.Unlock()
+		return nil
+	}, func(path string, _ fs.FileInfo) {
+		if found != nil {
+			found(path)
+		}
+	})
+}
+
+func (in *inotify) run(ctx context.Context, emit func(path string)) error {
+	go func() {
+		<-ctx.Done()
+		in.file.Close()
+	}()
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := in.file.Read(buf)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			nameBytes := buf[off+syscall.SizeofInotifyEvent : off+syscall.SizeofInotifyEvent+int(ev.Len)]
+			off += syscall.SizeofInotifyEvent + int(ev.Len)
+
+			if ev.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				// Events were dropped; report everything so nothing is missed
+				walk(in.root, in.filter, nil, func(path string, _ fs.FileInfo) { emit(path) })
+				continue
+			}
+			if ev.Mask&syscall.IN_IGNORED != 0 {
+				in.mu.Lock()
+				delete(in.dirs, ev.Wd)
+				in.mu.Unlock()
+				continue
+			}
+
+			in.mu.Lock()
+			dir, ok := in.dirs[ev.Wd]
+			in.mu.Unlock()
+			if !ok {
+				continue
+			}
+			name := strings.TrimRight(string(nameBytes), "\x00")
+			if name == "" {
+				continue
+			}
+			path := filepath.Join(dir, name)
+
+			if ev.Mask&syscall.IN_ISDIR != 0 {
+				// New or moved-in directories are watched, and their files
+				// reported, since they were never seen before
+				if ev.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 && !in.filter.SkipsDir(path) {
+					in.addTree(path, emit)
+				}
+				continue
+			}
+			if in.filter.Accepts(path) {
+				emit(path)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watch
+
+import "errors"
+
+// newNative has no event source outside Linux, so New falls back to polling
+func newNative(root string, filter Filter) (backend, error) {
+	return nil, errors.New("native file events not supported on this platform")
+}
@@ -0,0 +1,69 @@
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"time"
+)
+
+type fileStamp struct {
+	size    int64
+	modTime int64
+}
+
+// poller finds changes by comparing size and modification time snapshots
+type poller struct {
+	root     string
+	filter   Filter
+	interval time.Duration
+	last     map[string]fileStamp
+}
+
+func newPoller(root string, filter Filter, interval time.Duration) (*poller, error) {
+	p := &poller{root: root, filter: filter, interval: interval}
+	snap, err := p.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	p.last = snap
+	return p, nil
+}
+
+func (p *poller) name() string { return "poll" }
+
+func (p *poller) snapshot() (map[string]fileStamp, error) {
+	snap := make(map[string]fileStamp)
+	err := walk(p.root, p.filter, nil, func(path string, info fs.FileInfo) {
+		snap[path] = fileStamp{size: info.Size(), modTime: info.ModTime().UnixNano()}
+	})
+	return snap, err
+}
+
+func (p *poller) run(ctx context.Context, emit func(path string)) error {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		snap, err := p.snapshot()
+		if err != nil {
+			return err
+		}
+		for path, st := range snap {
+			if old, ok := p.last[path]; !ok || old != st {
+				emit(path)
+			}
+		}
+		for path := range p.last {
+			if _, ok := snap[path]; !ok {
+				emit(path)
+			}
+		}
+		p.last = snap
+	}
+}
@@ -0,0 +1,149 @@
+// Package watch reports changed files under a directory tree. It uses inotify
+// on Linux and falls back to polling modification times elsewhere, or when
+// inotify is unavailable.
+package watch
+
+import (
+	"context"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Filter decides which files and directories are watched
+type Filter interface {
+	Accepts(path string) bool
+	SkipsDir(path string) bool
+}
+
+// Options configures a Watcher
+type Options struct {
+	Debounce time.Duration // quiet period before a batch is delivered (default 300ms)
+	Interval time.Duration // polling interval (default 1s)
+	Poll     bool          // use polling even when native events are available
+}
+
+// backend produces raw change events for single paths
+type backend interface {
+	run(ctx context.Context, emit func(path string)) error
+	name() string
+}
+
+// Watcher delivers debounced batches of changed files
+type Watcher struct {
+	root    string
+	filter  Filter
+	opts    Options
+	backend backend
+}
+
+// New prepares a watcher for root. Native events are tried first unless
+// opts.Poll is set; any failure to set them up falls back to polling.
+func New(root string, filter Filter, opts Options) (*Watcher, error) {
+	if opts.Debounce <= 0 {
+		opts.Debounce = 300 * time.Millisecond
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Second
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{root: root, filter: filter, opts: opts}
+	if !opts.Poll {
+		if b, err := newNative(root, filter); err == nil {
+			w.backend = b
+		}
+	}
+	if w.backend == nil {
+		b, err := newPoller(root, filter, opts.Interval)
+		if err != nil {
+			return nil, err
+		}
+		w.backend = b
+	}
+	return w, nil
+}
+
+// Backend names the mechanism in use: "inotify" or "poll"
+func (w *Watcher) Backend() string {
+	return w.backend.name()
+}
+
+// Run blocks until ctx is done, calling onChange with the sorted set of
+// files that changed (or were removed) once events go quiet for the debounce
+// period. onChange runs on the watcher's goroutine, so events that arrive
+// while it runs are batched for the next call.
+func (w *Watcher) Run(ctx context.Context, onChange func(paths []string)) error {
+	events := make(chan string, 256)
+	errc := make(chan error, 1)
+	go func() {
+		errc <- w.backend.run(ctx, func(path string) {
+			select {
+			case events <- path:
+			case <-ctx.Done():
+			}
+		})
+	}()
+
+	pending := make(map[string]bool)
+	timer := time.NewTimer(w.opts.Debounce)
+	timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errc:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		case path := <-events:
+			pending[path] = true
+			timer.Reset(w.opts.Debounce)
+		case <-timer.C:
+			if len(pending) == 0 {
+				continue
+			}
+			batch := make([]string, 0, len(pending))
+			for p := range pending {
+				batch = append(batch, p)
+			}
+			sort.Strings(batch)
+			pending = make(map[string]bool)
+			onChange(batch)
+		}
+	}
+}
+
+// walk visits every accepted file under root, skipping filtered directories
+func walk(root string, filter Filter, dirFn func(dir string) error, fileFn func(path string, info fs.FileInfo)) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Files can vanish mid-walk while being edited
+			if path == root {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if path != root && filter.SkipsDir(path) {
+				return filepath.SkipDir
+			}
+			if dirFn != nil {
+				return dirFn(path)
+			}
+			return nil
+		}
+		if fileFn != nil && filter.Accepts(path) {
+			if info, err := d.Info(); err == nil {
+				fileFn(path, info)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,59 @@
+package watch
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// script is a backend that emits bursts of events with pauses between them
+type script struct {
+	bursts [][]string
+	pause  time.Duration
+}
+
+func (s *script) name() string { return "script" }
+
+func (s *script) run(ctx context.Context, emit func(path string)) error {
+	for _, burst := range s.bursts {
+		for _, path := range burst {
+			emit(path)
+		}
+		select {
+		case <-time.After(s.pause):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	<-ctx.Done()
+	return nil
+}
+
+func TestRunDebounce(t *testing.T) {
+	b := &script{
+		bursts: [][]string{
+			{"/r/b.md", "/r/a.md", "/r/b.md", "/r/a.md"},
+			{"/r/c.md"},
+		},
+		pause: 200 * time.Millisecond,
+	}
+	w := &Watcher{root: "/r", opts: Options{Debounce: 50 * time.Millisecond}, backend: b}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var batches [][]string
+	err := w.Run(ctx, func(paths []string) {
+		batches = append(batches, paths)
+		if len(batches) == 2 {
+			cancel()
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"/r/a.md", "/r/b.md"}, {"/r/c.md"}}
+	if !reflect.DeepEqual(batches, want) {
+		t.Errorf("batches = %q, want %q", batches, want)
+	}
+}